@@ -16,10 +16,14 @@ func main() {
 	var (
 		rootTimeout time.Duration
 		useCancel   bool
+		graceful    bool
+		gracePeriod time.Duration
 	)
 
 	conf.Var(conf.Environ, &rootTimeout, "ROOT_TIMEOUT")
 	conf.Var(conf.Environ, &useCancel, "USE_CANCEL")
+	conf.Var(conf.Environ, &graceful, "GRACEFUL")
+	conf.Var(conf.Environ, &gracePeriod, "GRACE_PERIOD")
 	conf.Environ.MustParse()
 
 	ctx, cancel := func() (context.Context, context.CancelFunc) {
@@ -31,6 +35,19 @@ func main() {
 
 	defer cancel()
 
+	if graceful {
+		soft, hard, cancel := xcontext.WithGracefulShutdown(ctx, gracePeriod, syscall.SIGINT)
+		defer cancel()
+
+		<-soft.Done()
+		fmt.Println(context.Cause(soft))
+
+		<-hard.Done()
+		fmt.Println(context.Cause(hard))
+
+		return
+	}
+
 	ctx, cancel = xcontext.WithSignalCancelation(ctx, syscall.SIGINT)
 	defer cancel()
 
@@ -0,0 +1,114 @@
+package xcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CauseKind classifies why a context is done.
+type CauseKind int
+
+const (
+	// CauseNone means the context is not done.
+	CauseNone CauseKind = iota
+	// CauseSignal means the context was canceled by a signal: its cause is a SignalCancelError, or wraps one such as a
+	// GracePeriodError.
+	CauseSignal
+	// CauseDeadline means the context's deadline was exceeded, or its cause wraps context.DeadlineExceeded such as
+	// ErrIdle.
+	CauseDeadline
+	// CauseExplicit means the context was canceled with a cause other than a signal or a deadline.
+	CauseExplicit
+	// CauseCanceled means the context was canceled without a cause, either by its own CancelFunc or because a parent
+	// was. The context package does not tell them apart.
+	CauseCanceled
+)
+
+func (kind CauseKind) String() string {
+	switch kind {
+	case CauseNone:
+		return "none"
+	case CauseSignal:
+		return "signal"
+	case CauseDeadline:
+		return "deadline"
+	case CauseExplicit:
+		return "explicit"
+	case CauseCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("CauseKind(%d)", int(kind))
+	}
+}
+
+// Classify returns the kind of cancelation cause of ctx. The cause of a context returned by Merge is classified by the
+// cause of the input that was done.
+func Classify(ctx context.Context) CauseKind {
+	if ctx.Err() == nil {
+		return CauseNone
+	}
+	return classify(context.Cause(ctx))
+}
+
+func classify(cause error) CauseKind {
+	if cause == nil {
+		return CauseNone
+	}
+	if sigErr := (SignalCancelError{}); errors.As(cause, &sigErr) {
+		return CauseSignal
+	}
+	if errors.Is(cause, context.DeadlineExceeded) {
+		return CauseDeadline
+	}
+	if merged := (MergeCancelError{}); errors.As(cause, &merged) {
+		return classify(merged.Cause)
+	}
+	if cause == context.Canceled {
+		return CauseCanceled
+	}
+	return CauseExplicit
+}
+
+// Describe returns a human-readable explanation of why ctx is done, naming the signal, deadline or cause involved. It
+// is meant for shutdown logs, where ctx.Err() reads "context canceled" whatever happened. For a context returned by
+// Detach, it also explains why the context it was detached from is done.
+func Describe(ctx context.Context) string {
+	description := "not done"
+	if ctx.Err() != nil {
+		deadline, _ := ctx.Deadline()
+		description = describe(context.Cause(ctx), deadline)
+	}
+	if cause := DetachedCause(ctx); cause != nil {
+		description += fmt.Sprintf(" (detached from a context that is done: %s)", describe(cause, time.Time{}))
+	}
+	return description
+}
+
+func describe(cause error, deadline time.Time) string {
+	if merged := (MergeCancelError{}); errors.As(cause, &merged) {
+		return fmt.Sprintf("merged context %d done: %s", merged.Index, describe(merged.Cause, deadline))
+	}
+	if grace := (GracePeriodError{}); errors.As(cause, &grace) {
+		return fmt.Sprintf("grace period of %s elapsed after signal %s", grace.Grace, grace.Signal)
+	}
+	if sigErr := (SignalCancelError{}); errors.As(cause, &sigErr) {
+		return fmt.Sprintf("canceled by signal %s", sigErr.Signal)
+	}
+
+	switch {
+	case cause == context.DeadlineExceeded && !deadline.IsZero():
+		return fmt.Sprintf("deadline of %s exceeded", deadline.Format(time.RFC3339Nano))
+	case cause == context.DeadlineExceeded:
+		return "deadline exceeded"
+	case errors.Is(cause, ErrIdle):
+		return "idle timeout exceeded"
+	case errors.Is(cause, context.DeadlineExceeded):
+		return fmt.Sprintf("deadline exceeded: %v", cause)
+	case cause == context.Canceled:
+		return "canceled without a cause"
+	default:
+		return fmt.Sprintf("canceled: %v", cause)
+	}
+}
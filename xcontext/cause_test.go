@@ -0,0 +1,135 @@
+package xcontext_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyAndDescribe(t *testing.T) {
+	canceledWith := func(cause error) context.Context {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(cause)
+		return ctx
+	}
+
+	deadline := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), deadline)
+	defer cancelExpired()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	child, cancelChild := context.WithCancel(canceledWith(xcontext.SignalCancelError{Signal: syscall.SIGINT}))
+	defer cancelChild()
+
+	merged, cancelMerged := xcontext.Merge(context.Background(), canceledWith(xcontext.SignalCancelError{Signal: syscall.SIGTERM}))
+	defer cancelMerged()
+
+	mergedDeadline, cancelMergedDeadline := xcontext.Merge(expired)
+	defer cancelMergedDeadline()
+
+	detached, cancelDetached := xcontext.Detach(canceledWith(errors.New("client disconnected")), 0)
+	defer cancelDetached()
+
+	cases := []struct {
+		Name        string
+		Ctx         context.Context
+		Kind        xcontext.CauseKind
+		Description string
+	}{
+		{
+			Name:        "not done",
+			Ctx:         context.Background(),
+			Kind:        xcontext.CauseNone,
+			Description: "not done",
+		},
+		{
+			Name:        "signal",
+			Ctx:         child,
+			Kind:        xcontext.CauseSignal,
+			Description: "canceled by signal interrupt",
+		},
+		{
+			Name:        "grace period",
+			Ctx:         canceledWith(xcontext.GracePeriodError{Signal: syscall.SIGTERM, Grace: 5 * time.Second}),
+			Kind:        xcontext.CauseSignal,
+			Description: "grace period of 5s elapsed after signal terminated",
+		},
+		{
+			Name:        "deadline",
+			Ctx:         expired,
+			Kind:        xcontext.CauseDeadline,
+			Description: "deadline of 2026-01-02T03:04:05Z exceeded",
+		},
+		{
+			Name:        "idle",
+			Ctx:         canceledWith(xcontext.ErrIdle),
+			Kind:        xcontext.CauseDeadline,
+			Description: "idle timeout exceeded",
+		},
+		{
+			Name:        "wrapped deadline",
+			Ctx:         canceledWith(fmt.Errorf("%w: upstream too slow", context.DeadlineExceeded)),
+			Kind:        xcontext.CauseDeadline,
+			Description: "deadline exceeded: context deadline exceeded: upstream too slow",
+		},
+		{
+			Name:        "explicit",
+			Ctx:         canceledWith(errors.New("config reloaded")),
+			Kind:        xcontext.CauseExplicit,
+			Description: "canceled: config reloaded",
+		},
+		{
+			Name:        "canceled",
+			Ctx:         canceled,
+			Kind:        xcontext.CauseCanceled,
+			Description: "canceled without a cause",
+		},
+		{
+			Name:        "merged signal",
+			Ctx:         merged,
+			Kind:        xcontext.CauseSignal,
+			Description: "merged context 1 done: canceled by signal terminated",
+		},
+		{
+			Name:        "merged deadline",
+			Ctx:         mergedDeadline,
+			Kind:        xcontext.CauseDeadline,
+			Description: "merged context 0 done: deadline of 2026-01-02T03:04:05Z exceeded",
+		},
+		{
+			Name:        "detached",
+			Ctx:         detached,
+			Kind:        xcontext.CauseNone,
+			Description: "not done (detached from a context that is done: canceled: client disconnected)",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.Kind, xcontext.Classify(tc.Ctx))
+			require.Equal(t, tc.Description, xcontext.Describe(tc.Ctx))
+		})
+	}
+}
+
+func TestCauseKindString(t *testing.T) {
+	require.Equal(t, "signal", xcontext.CauseSignal.String())
+	require.Equal(t, "CauseKind(42)", xcontext.CauseKind(42).String())
+}
+
+func TestGracePeriodError(t *testing.T) {
+	err := xcontext.GracePeriodError{Signal: syscall.SIGINT, Grace: 50 * time.Millisecond}
+
+	require.EqualError(t, err, "context canceled: received signal: interrupt: grace period of 50ms elapsed")
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, xcontext.SignalCancelError{Signal: syscall.SIGINT})
+}
@@ -0,0 +1,30 @@
+package xcontext
+
+import (
+	"context"
+	"time"
+)
+
+type detachedKey struct{}
+
+// Detach returns a context that keeps the values of parent, such as request IDs and trace information, but not its
+// cancelation, and that expires after timeout instead. It is meant for cleanup work that must run after parent is
+// done, such as audit writes or rollbacks. If timeout is not positive the returned context has no deadline.
+//
+// The cancelation cause of parent remains available via DetachedCause.
+func Detach(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(context.WithoutCancel(parent), detachedKey{}, parent)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// DetachedCause returns the cancelation cause of the context ctx was detached from by Detach, or nil if that context
+// is not done or ctx was not detached.
+func DetachedCause(ctx context.Context) error {
+	if parent, ok := ctx.Value(detachedKey{}).(context.Context); ok {
+		return context.Cause(parent)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package xcontext_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetach(t *testing.T) {
+	type key struct{}
+
+	parent, cancelParent := context.WithCancelCause(context.WithValue(context.Background(), key{}, "request-id"))
+	cancelParent(xcontext.SignalCancelError{Signal: syscall.SIGTERM})
+
+	ctx, cancel := xcontext.Detach(parent, 20*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, ctx.Err())
+	require.Equal(t, "request-id", ctx.Value(key{}))
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(20*time.Millisecond), deadline, 10*time.Millisecond)
+
+	require.Equal(t, syscall.SIGTERM, xcontext.SignalCause(parent))
+	require.Nil(t, xcontext.SignalCause(ctx))
+
+	cause := xcontext.DetachedCause(ctx)
+	require.ErrorIs(t, cause, context.Canceled)
+	require.Equal(t, xcontext.SignalCancelError{Signal: syscall.SIGTERM}, cause)
+
+	<-ctx.Done()
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
+
+func TestDetachBeforeParentDone(t *testing.T) {
+	parent, cancelParent := context.WithCancelCause(context.Background())
+
+	ctx, cancel := xcontext.Detach(parent, 0)
+
+	require.NoError(t, xcontext.DetachedCause(ctx))
+
+	cancelParent(errors.New("request aborted"))
+
+	require.NoError(t, ctx.Err())
+	require.EqualError(t, xcontext.DetachedCause(ctx), "request aborted")
+
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+
+	cancel()
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestDetachedCauseNotDetached(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.NoError(t, xcontext.DetachedCause(ctx))
+}
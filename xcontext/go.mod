@@ -4,12 +4,12 @@ go 1.26
 
 require (
 	github.com/davidmdm/conf v0.0.10
+	github.com/davidmdm/x/xerr v0.0.5
 	github.com/stretchr/testify v1.8.4
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/davidmdm/x/xerr v0.0.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
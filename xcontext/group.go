@@ -0,0 +1,102 @@
+package xcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/davidmdm/x/xerr"
+)
+
+type GroupOptions struct {
+	// Signals cancel the group's context when received. Defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+}
+
+type TaskOptions struct {
+	// MaxRestarts is the number of times the task is restarted after failing, before its failure cancels the group.
+	// If negative, the task is restarted until the group's context is done.
+	MaxRestarts int
+	// Backoff is the delay before each restart.
+	Backoff time.Duration
+}
+
+// Group supervises the goroutines of an application under a shared context, which is canceled on a signal or when a
+// task fails, such that the other tasks stop. Unlike Shutdown, which cleans up components once the application is
+// done, Group runs the components themselves. It must be created with NewGroup.
+type Group struct {
+	ctx         context.Context
+	cancelCause context.CancelCauseFunc
+	stopSignals context.CancelFunc
+
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup returns a group and the context shared by its tasks. The context is derived from WithSignalCancelation:
+// its cause is a SignalCancelError when a signal is received, or the error of the first task to fail.
+func NewGroup(parent context.Context, opts GroupOptions) (*Group, context.Context) {
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	signalCtx, stopSignals := WithSignalCancelation(parent, opts.Signals...)
+	ctx, cancelCause := context.WithCancelCause(signalCtx)
+
+	return &Group{ctx: ctx, cancelCause: cancelCause, stopSignals: stopSignals}, ctx
+}
+
+// Go runs fn on its own goroutine with the group's context. If fn returns an error it is restarted according to opts,
+// unless the group's context is done. Once it may no longer be restarted, its error, prefixed by name, cancels the
+// group's context. Go must not be called after Wait has returned.
+func (group *Group) Go(name string, fn func(ctx context.Context) error, opts TaskOptions) {
+	group.wg.Go(func() {
+		for restarts := 0; ; restarts++ {
+			err := fn(group.ctx)
+			if err == nil {
+				return
+			}
+
+			if group.ctx.Err() == nil && (opts.MaxRestarts < 0 || restarts < opts.MaxRestarts) {
+				if Sleep(group.ctx, opts.Backoff) == nil {
+					continue
+				}
+			}
+
+			group.fail(fmt.Errorf("%s: %w", name, err))
+			return
+		}
+	})
+}
+
+func (group *Group) fail(err error) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	// Errors returned by tasks reacting to the cancelation of the group are not failures of their own.
+	if cause := context.Cause(group.ctx); cause != nil && (errors.Is(err, context.Canceled) || errors.Is(err, cause)) {
+		return
+	}
+
+	group.errs = append(group.errs, err)
+	group.cancelCause(err)
+}
+
+// Wait waits for all tasks to return, and returns the errors of the tasks that failed as an xerr.MultiErr. Tasks that
+// return the cancelation error or cause of the group's context are not considered failed, such that stopping on a
+// signal returns nil. The group's context is canceled once Wait returns.
+func (group *Group) Wait() error {
+	group.wg.Wait()
+	group.stopSignals()
+	group.cancelCause(nil)
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	return xerr.MultiErrFrom("group", group.errs...)
+}
@@ -0,0 +1,108 @@
+package xcontext_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/davidmdm/x/xerr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("failure cancels the group", func(t *testing.T) {
+		group, ctx := xcontext.NewGroup(context.Background(), xcontext.GroupOptions{Signals: []os.Signal{syscall.SIGUSR2}})
+
+		group.Go("server", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, xcontext.TaskOptions{})
+
+		group.Go("worker", func(ctx context.Context) error {
+			<-ctx.Done()
+			return context.Cause(ctx)
+		}, xcontext.TaskOptions{})
+
+		group.Go("migrations", func(ctx context.Context) error { return nil }, xcontext.TaskOptions{})
+
+		group.Go("consumer", func(ctx context.Context) error {
+			return errors.New("broker unreachable")
+		}, xcontext.TaskOptions{})
+
+		err := group.Wait()
+		require.EqualError(t, err, "group: consumer: broker unreachable")
+
+		var multi xerr.MultiErr
+		require.ErrorAs(t, err, &multi)
+		require.Len(t, multi.Errors, 1)
+
+		require.EqualError(t, context.Cause(ctx), "consumer: broker unreachable")
+		require.Equal(t, xcontext.CauseExplicit, xcontext.Classify(ctx))
+	})
+
+	t.Run("restarts", func(t *testing.T) {
+		group, ctx := xcontext.NewGroup(context.Background(), xcontext.GroupOptions{Signals: []os.Signal{syscall.SIGUSR2}})
+
+		var flakyRuns atomic.Int32
+		group.Go("flaky", func(ctx context.Context) error {
+			if flakyRuns.Add(1) < 3 {
+				return errors.New("flake")
+			}
+			return nil
+		}, xcontext.TaskOptions{MaxRestarts: 2, Backoff: time.Millisecond})
+
+		require.NoError(t, group.Wait())
+		require.Equal(t, int32(3), flakyRuns.Load())
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+
+		group, _ = xcontext.NewGroup(context.Background(), xcontext.GroupOptions{Signals: []os.Signal{syscall.SIGUSR2}})
+
+		var brokenRuns atomic.Int32
+		group.Go("broken", func(ctx context.Context) error {
+			brokenRuns.Add(1)
+			return errors.New("broken")
+		}, xcontext.TaskOptions{MaxRestarts: 2})
+
+		require.EqualError(t, group.Wait(), "group: broken: broken")
+		require.Equal(t, int32(3), brokenRuns.Load())
+	})
+
+	t.Run("restarts stop once the group is canceled", func(t *testing.T) {
+		parent, cancel := context.WithCancel(context.Background())
+
+		group, _ := xcontext.NewGroup(parent, xcontext.GroupOptions{Signals: []os.Signal{syscall.SIGUSR2}})
+
+		var runs atomic.Int32
+		group.Go("forever", func(ctx context.Context) error {
+			if runs.Add(1) == 3 {
+				cancel()
+			}
+			return errors.New("failed")
+		}, xcontext.TaskOptions{MaxRestarts: -1, Backoff: time.Millisecond})
+
+		require.EqualError(t, group.Wait(), "group: forever: failed")
+		require.Equal(t, int32(3), runs.Load())
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		group, ctx := xcontext.NewGroup(context.Background(), xcontext.GroupOptions{Signals: []os.Signal{syscall.SIGUSR2}})
+
+		started := make(chan struct{})
+		group.Go("server", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}, xcontext.TaskOptions{})
+
+		<-started
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+
+		require.NoError(t, group.Wait())
+		require.Equal(t, syscall.SIGUSR2, xcontext.SignalCause(ctx))
+	})
+}
@@ -0,0 +1,48 @@
+package xcontext
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrIdle is the cancelation cause of contexts returned by WithIdleTimeout that were not touched in time.
+var ErrIdle = fmt.Errorf("%w: idle timeout", context.DeadlineExceeded)
+
+// WithIdleTimeout returns a context that is canceled with the ErrIdle cause unless touch is called at least once every
+// timeout. It is the rolling timeout of the xhttp.TimeoutHandler for any long-running job: a websocket session touches
+// its context on every message, and a worker renews its lease by touching it. Touch is safe for concurrent use and does
+// nothing once the context is done. As with context.WithTimeout, a non-positive timeout returns a context that is
+// already done, with the ErrIdle cause.
+func WithIdleTimeout(parent context.Context, timeout time.Duration) (ctx context.Context, touch func(), cancel context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(parent)
+
+	if timeout <= 0 {
+		cancelCause(ErrIdle)
+	}
+
+	var mu sync.Mutex
+
+	timer := time.AfterFunc(timeout, func() { cancelCause(ErrIdle) })
+
+	// Release the timer however the context ends, including when the parent is done first.
+	context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		timer.Stop()
+	})
+
+	touch = func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		timer.Reset(timeout)
+	}
+
+	return ctx, touch, func() { cancelCause(nil) }
+}
@@ -0,0 +1,66 @@
+package xcontext_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithIdleTimeout(t *testing.T) {
+	t.Run("touched", func(t *testing.T) {
+		ctx, touch, cancel := xcontext.WithIdleTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		for range 5 {
+			time.Sleep(20 * time.Millisecond)
+			require.NoError(t, ctx.Err())
+			touch()
+		}
+
+		<-ctx.Done()
+
+		require.Greater(t, time.Since(start), 120*time.Millisecond)
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.ErrorIs(t, context.Cause(ctx), xcontext.ErrIdle)
+		require.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+
+		touch()
+		require.ErrorIs(t, context.Cause(ctx), xcontext.ErrIdle)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, touch, cancel := xcontext.WithIdleTimeout(context.Background(), 10*time.Millisecond)
+
+		cancel()
+		touch()
+		time.Sleep(20 * time.Millisecond)
+
+		require.Equal(t, context.Canceled, context.Cause(ctx))
+	})
+
+	t.Run("non-positive timeout", func(t *testing.T) {
+		ctx, touch, cancel := xcontext.WithIdleTimeout(context.Background(), 0)
+		defer cancel()
+
+		touch()
+
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.ErrorIs(t, context.Cause(ctx), xcontext.ErrIdle)
+	})
+
+	t.Run("parent canceled", func(t *testing.T) {
+		parent, cancelParent := context.WithCancel(context.Background())
+
+		ctx, _, cancel := xcontext.WithIdleTimeout(parent, time.Hour)
+		defer cancel()
+
+		cancelParent()
+
+		<-ctx.Done()
+		require.Equal(t, context.Canceled, context.Cause(ctx))
+	})
+}
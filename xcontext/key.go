@@ -0,0 +1,55 @@
+package xcontext
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key is a typed context key, which replaces an unexported key type and the type assertions of context.Value. Keys
+// are compared by identity: two keys created by separate calls to NewKey never collide, even with the same name and
+// type. Keys are meant to be created once, as package-level variables.
+type Key[T any] struct {
+	name       string
+	value      T
+	hasDefault bool
+}
+
+// NewKey returns a new key for values of type T. The name is only used for debugging, as the String of the key.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// NewKeyWithDefault returns a new key for values of type T, whose Value and MustValue return value for contexts that
+// do not hold one.
+func NewKeyWithDefault[T any](name string, value T) *Key[T] {
+	return &Key[T]{name: name, value: value, hasDefault: true}
+}
+
+// WithValue returns a copy of ctx in which the key is associated with value.
+func (key *Key[T]) WithValue(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, key, value)
+}
+
+// Value returns the value associated with the key in ctx, and whether there is one. If there is none, it returns the
+// default value of the key, or the zero value of T if the key has no default.
+func (key *Key[T]) Value(ctx context.Context) (T, bool) {
+	value, ok := ctx.Value(key).(T)
+	if !ok {
+		return key.value, false
+	}
+	return value, true
+}
+
+// MustValue returns the value associated with the key in ctx, or the default value of the key. It panics if there is
+// neither, which is a programming error such as a missing middleware.
+func (key *Key[T]) MustValue(ctx context.Context) T {
+	value, ok := key.Value(ctx)
+	if !ok && !key.hasDefault {
+		panic(fmt.Sprintf("xcontext: no value for key %s", key.name))
+	}
+	return value
+}
+
+func (key *Key[T]) String() string {
+	return key.name
+}
@@ -0,0 +1,43 @@
+package xcontext_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	var (
+		requestID = xcontext.NewKey[string]("request-id")
+		tenant    = xcontext.NewKey[string]("request-id")
+		retries   = xcontext.NewKeyWithDefault("retries", 3)
+	)
+
+	ctx := requestID.WithValue(context.Background(), "abc")
+
+	value, ok := requestID.Value(ctx)
+	require.True(t, ok)
+	require.Equal(t, "abc", value)
+	require.Equal(t, "abc", requestID.MustValue(ctx))
+
+	value, ok = tenant.Value(ctx)
+	require.False(t, ok)
+	require.Empty(t, value)
+	require.PanicsWithValue(t, "xcontext: no value for key request-id", func() { tenant.MustValue(ctx) })
+
+	count, ok := retries.Value(ctx)
+	require.False(t, ok)
+	require.Equal(t, 3, count)
+	require.Equal(t, 3, retries.MustValue(ctx))
+
+	ctx = retries.WithValue(ctx, 0)
+
+	count, ok = retries.Value(ctx)
+	require.True(t, ok)
+	require.Equal(t, 0, count)
+
+	require.Equal(t, "request-id", requestID.String())
+	require.Contains(t, ctx.(interface{ String() string }).String(), "WithValue(request-id, abc)")
+}
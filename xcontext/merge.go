@@ -0,0 +1,121 @@
+package xcontext
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MergeCancelError is the cancelation cause of a context returned by Merge when one of its inputs is done.
+type MergeCancelError struct {
+	// Index is the position of the input that was done first.
+	Index int
+	// Cause is the cancelation cause of that input.
+	Cause error
+}
+
+func (err MergeCancelError) Error() string {
+	return fmt.Sprintf("merged context %d done: %v", err.Index, err.Cause)
+}
+
+func (err MergeCancelError) Unwrap() error {
+	return err.Cause
+}
+
+// Merge returns a context that is done as soon as any of ctxs is done, or when cancel is called. Its cause is a
+// MergeCancelError identifying which input was done and why, such that SignalCause still reports signals received by
+// any input. Its error is the error of that input. Its deadline is the earliest deadline of the inputs, and values are
+// looked up in the inputs in order.
+func Merge(ctxs ...context.Context) (context.Context, context.CancelFunc) {
+	base, cancelCause := context.WithCancelCause(context.Background())
+
+	merged := &mergedContext{Context: base, ctxs: ctxs}
+
+	var (
+		once    sync.Once
+		mu      sync.Mutex
+		stops   []func() bool
+		stopped bool
+	)
+
+	done := func(err, cause error) {
+		once.Do(func() {
+			merged.mu.Lock()
+			merged.err = err
+			merged.mu.Unlock()
+
+			cancelCause(cause)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			stopped = true
+			for _, stop := range stops {
+				stop()
+			}
+		})
+	}
+
+	for i, ctx := range ctxs {
+		if err := ctx.Err(); err != nil {
+			done(err, MergeCancelError{Index: i, Cause: context.Cause(ctx)})
+			return merged, func() {}
+		}
+	}
+
+	for i, ctx := range ctxs {
+		stop := context.AfterFunc(ctx, func() {
+			done(ctx.Err(), MergeCancelError{Index: i, Cause: context.Cause(ctx)})
+		})
+
+		mu.Lock()
+		if stopped {
+			stop()
+		}
+		stops = append(stops, stop)
+		mu.Unlock()
+	}
+
+	return merged, func() { done(context.Canceled, nil) }
+}
+
+type mergedContext struct {
+	// Context is the context actually canceled. It carries the cause used by context.Cause.
+	context.Context
+	ctxs []context.Context
+
+	mu  sync.Mutex
+	err error
+}
+
+func (ctx *mergedContext) Deadline() (deadline time.Time, ok bool) {
+	for _, input := range ctx.ctxs {
+		if d, hasDeadline := input.Deadline(); hasDeadline && (!ok || d.Before(deadline)) {
+			deadline, ok = d, true
+		}
+	}
+	return
+}
+
+func (ctx *mergedContext) Err() error {
+	if ctx.Context.Err() == nil {
+		return nil
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.err
+}
+
+func (ctx *mergedContext) Value(key any) any {
+	// The base context only holds the internal values of the context package, such as the one used by context.Cause.
+	if value := ctx.Context.Value(key); value != nil {
+		return value
+	}
+	for _, input := range ctx.ctxs {
+		if value := input.Value(key); value != nil {
+			return value
+		}
+	}
+	return nil
+}
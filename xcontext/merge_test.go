@@ -0,0 +1,92 @@
+package xcontext_test
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	type key string
+
+	t.Run("canceled by an input", func(t *testing.T) {
+		request, cancelRequest := context.WithCancel(context.WithValue(context.Background(), key("a"), "request"))
+		defer cancelRequest()
+
+		shutdown, cancelShutdown := context.WithCancelCause(context.WithValue(context.Background(), key("b"), "shutdown"))
+
+		ctx, cancel := xcontext.Merge(request, shutdown)
+		defer cancel()
+
+		require.NoError(t, ctx.Err())
+		require.Equal(t, "request", ctx.Value(key("a")))
+		require.Equal(t, "shutdown", ctx.Value(key("b")))
+		require.Nil(t, ctx.Value(key("c")))
+
+		cancelShutdown(xcontext.SignalCancelError{Signal: syscall.SIGTERM})
+
+		<-ctx.Done()
+
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.Equal(
+			t,
+			xcontext.MergeCancelError{Index: 1, Cause: xcontext.SignalCancelError{Signal: syscall.SIGTERM}},
+			context.Cause(ctx),
+		)
+		require.EqualError(t, context.Cause(ctx), "merged context 1 done: context canceled: received signal: terminated")
+		require.Equal(t, syscall.SIGTERM, xcontext.SignalCause(ctx))
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		late, cancelLate := context.WithTimeout(context.Background(), time.Hour)
+		defer cancelLate()
+
+		early, cancelEarly := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelEarly()
+
+		ctx, cancel := xcontext.Merge(late, early, context.Background())
+		defer cancel()
+
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+
+		expected, _ := early.Deadline()
+		require.Equal(t, expected, deadline)
+
+		<-ctx.Done()
+
+		require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+		require.Equal(t, xcontext.MergeCancelError{Index: 1, Cause: context.DeadlineExceeded}, context.Cause(ctx))
+	})
+
+	t.Run("input already done", func(t *testing.T) {
+		done, cancelDone := context.WithCancel(context.Background())
+		cancelDone()
+
+		ctx, cancel := xcontext.Merge(context.Background(), done)
+		defer cancel()
+
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.Equal(t, xcontext.MergeCancelError{Index: 1, Cause: context.Canceled}, context.Cause(ctx))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := xcontext.Merge(context.Background(), context.Background())
+
+		child, cancelChild := context.WithCancel(ctx)
+		defer cancelChild()
+
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+
+		cancel()
+
+		<-child.Done()
+		require.ErrorIs(t, ctx.Err(), context.Canceled)
+		require.Equal(t, context.Canceled, context.Cause(ctx))
+	})
+}
@@ -0,0 +1,142 @@
+package xcontext
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/davidmdm/x/xerr"
+)
+
+type HookOptions struct {
+	// Timeout bounds the duration of the hook. When it elapses the hook's context is canceled and the shutdown moves on
+	// to the next hook without waiting for the hook to return. If zero, the hook is not bounded.
+	Timeout time.Duration
+	// Priority orders hooks: hooks with a higher priority run first. Hooks of equal priority run in reverse
+	// registration order.
+	Priority int
+	// DependsOn lists the names of the hooks of the components this component depends on. A hook always runs before
+	// the hooks it depends on, regardless of priority: the HTTP server is closed before the database it queries.
+	DependsOn []string
+}
+
+// Shutdown coordinates the cleanup of an application's components. Components register hooks as they are started,
+// and the hooks run once, in order, when the application shuts down. The zero value is ready to use.
+type Shutdown struct {
+	mu    sync.Mutex
+	hooks []hook
+	done  bool
+}
+
+type hook struct {
+	name string
+	fn   func(context.Context) error
+	opts HookOptions
+}
+
+// Register registers a cleanup hook under the given name. Hooks registered after the shutdown has run are ignored.
+func (shutdown *Shutdown) Register(name string, fn func(ctx context.Context) error, opts HookOptions) {
+	shutdown.mu.Lock()
+	defer shutdown.mu.Unlock()
+
+	if shutdown.done {
+		return
+	}
+	shutdown.hooks = append(shutdown.hooks, hook{name: name, fn: fn, opts: opts})
+}
+
+// Wait blocks until ctx is done, typically a context returned by WithSignalCancelation, and then runs the hooks.
+// The hooks' contexts keep the values of ctx but not its cancelation.
+func (shutdown *Shutdown) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	return shutdown.Run(context.WithoutCancel(ctx))
+}
+
+// Run runs the registered hooks one after the other, and returns their errors as an xerr.MultiErr. Hooks whose
+// dependencies form a cycle are reported as errors and run last. Run only runs the hooks once: subsequent calls return
+// nil. Canceling ctx cancels the running hook and skips the remaining ones.
+func (shutdown *Shutdown) Run(ctx context.Context) error {
+	shutdown.mu.Lock()
+	if shutdown.done {
+		shutdown.mu.Unlock()
+		return nil
+	}
+	shutdown.done = true
+	hooks := shutdown.hooks
+	shutdown.mu.Unlock()
+
+	ordered, err := orderHooks(hooks)
+
+	errs := []error{err}
+	for _, h := range ordered {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: skipped: %w", h.name, context.Cause(ctx)))
+			continue
+		}
+		if err := h.run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+
+	return xerr.MultiErrFrom("shutdown", errs...)
+}
+
+func (h hook) run(ctx context.Context) error {
+	if h.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.Timeout)
+		defer cancel()
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- h.fn(ctx) }()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// orderHooks sorts hooks by priority and reverse registration order, then moves each hook ahead of the hooks it
+// depends on.
+func orderHooks(hooks []hook) ([]hook, error) {
+	base := slices.Clone(hooks)
+	slices.Reverse(base)
+	slices.SortStableFunc(base, func(a, b hook) int { return cmp.Compare(b.opts.Priority, a.opts.Priority) })
+
+	// dependents counts, for each hook name, the hooks depending on it that have yet to run.
+	dependents := map[string]int{}
+	for _, h := range base {
+		for _, dependency := range h.opts.DependsOn {
+			dependents[dependency]++
+		}
+	}
+
+	ordered := make([]hook, 0, len(base))
+
+	for len(base) > 0 {
+		idx := slices.IndexFunc(base, func(h hook) bool { return dependents[h.name] == 0 })
+		if idx < 0 {
+			names := make([]string, len(base))
+			for i, h := range base {
+				names[i] = h.name
+			}
+			return append(ordered, base...), fmt.Errorf("dependency cycle between hooks: %v", names)
+		}
+
+		h := base[idx]
+		base = slices.Delete(base, idx, idx+1)
+		ordered = append(ordered, h)
+
+		for _, dependency := range h.opts.DependsOn {
+			dependents[dependency]--
+		}
+	}
+
+	return ordered, nil
+}
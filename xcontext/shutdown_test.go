@@ -0,0 +1,182 @@
+package xcontext_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/davidmdm/x/xerr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	type Hook struct {
+		Name string
+		Opts xcontext.HookOptions
+	}
+
+	cases := []struct {
+		Name     string
+		Hooks    []Hook
+		Expected []string
+		Err      string
+	}{
+		{
+			Name:     "reverse registration order",
+			Hooks:    []Hook{{Name: "db"}, {Name: "queue"}, {Name: "server"}},
+			Expected: []string{"server", "queue", "db"},
+		},
+		{
+			Name: "priority",
+			Hooks: []Hook{
+				{Name: "db"},
+				{Name: "metrics", Opts: xcontext.HookOptions{Priority: -1}},
+				{Name: "server", Opts: xcontext.HookOptions{Priority: 1}},
+				{Name: "queue"},
+			},
+			Expected: []string{"server", "queue", "db", "metrics"},
+		},
+		{
+			Name: "dependencies",
+			Hooks: []Hook{
+				{Name: "server", Opts: xcontext.HookOptions{DependsOn: []string{"db", "queue"}}},
+				{Name: "queue", Opts: xcontext.HookOptions{DependsOn: []string{"db"}}},
+				{Name: "db", Opts: xcontext.HookOptions{Priority: 10}},
+			},
+			Expected: []string{"server", "queue", "db"},
+		},
+		{
+			Name: "dependency cycle",
+			Hooks: []Hook{
+				{Name: "a", Opts: xcontext.HookOptions{DependsOn: []string{"b"}}},
+				{Name: "b", Opts: xcontext.HookOptions{DependsOn: []string{"a"}}},
+				{Name: "c"},
+			},
+			Expected: []string{"c", "b", "a"},
+			Err:      "shutdown: dependency cycle between hooks: [b a]",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var (
+				shutdown xcontext.Shutdown
+				order    []string
+			)
+
+			for _, hook := range tc.Hooks {
+				shutdown.Register(hook.Name, func(context.Context) error {
+					order = append(order, hook.Name)
+					return nil
+				}, hook.Opts)
+			}
+
+			err := shutdown.Run(context.Background())
+			if tc.Err != "" {
+				require.EqualError(t, err, tc.Err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tc.Expected, order)
+		})
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	var (
+		shutdown xcontext.Shutdown
+		mu       sync.Mutex
+		ran      []string
+		release  = make(chan struct{})
+	)
+	defer close(release)
+
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, name)
+	}
+
+	shutdown.Register("db", func(context.Context) error {
+		record("db")
+		return errors.New("connection reset")
+	}, xcontext.HookOptions{})
+
+	shutdown.Register("queue", func(ctx context.Context) error {
+		record("queue")
+		<-release // ignores its context
+		return nil
+	}, xcontext.HookOptions{Timeout: 10 * time.Millisecond})
+
+	shutdown.Register("server", func(ctx context.Context) error {
+		record("server")
+		return nil
+	}, xcontext.HookOptions{})
+
+	err := shutdown.Run(context.Background())
+
+	mu.Lock()
+	require.Equal(t, []string{"server", "queue", "db"}, ran)
+	mu.Unlock()
+
+	var multi xerr.MultiErr
+	require.ErrorAs(t, err, &multi)
+	require.Len(t, multi.Errors, 2)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err, "shutdown:\n  - queue: context deadline exceeded\n  - db: connection reset")
+
+	// Hooks only run once.
+	require.NoError(t, shutdown.Run(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"server", "queue", "db"}, ran)
+}
+
+func TestShutdownWait(t *testing.T) {
+	type key struct{}
+
+	var (
+		shutdown xcontext.Shutdown
+		value    any
+		hookErr  error
+	)
+
+	shutdown.Register("hook", func(ctx context.Context) error {
+		value = ctx.Value(key{})
+		hookErr = ctx.Err()
+		return nil
+	}, xcontext.HookOptions{})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+
+	done := make(chan error, 1)
+	go func() { done <- shutdown.Wait(ctx) }()
+
+	select {
+	case <-done:
+		t.Fatal("shutdown ran before the context was canceled")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+
+	require.NoError(t, <-done)
+	require.Equal(t, "value", value)
+	require.NoError(t, hookErr)
+}
+
+func TestShutdownCanceled(t *testing.T) {
+	var shutdown xcontext.Shutdown
+
+	shutdown.Register("db", func(context.Context) error { return nil }, xcontext.HookOptions{})
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("out of time"))
+
+	require.EqualError(t, shutdown.Run(ctx), "shutdown: db: skipped: out of time")
+}
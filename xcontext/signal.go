@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"os"
 	"os/signal"
 	"sync"
+	"time"
 )
 
 func WithSignalCancelation(parent context.Context, signals ...os.Signal) (ctx context.Context, cancel context.CancelFunc) {
@@ -45,6 +47,126 @@ func WithSignalCancelation(parent context.Context, signals ...os.Signal) (ctx co
 	return ctx, cancel
 }
 
+// WithGracefulShutdown returns two contexts for a two-stage shutdown. The soft context is canceled on the first signal
+// received, and is meant to start draining work. The hard context is canceled on a second signal, or once the grace
+// period has elapsed after the first signal, and is meant to abandon whatever work remains. If grace is not positive,
+// only a second signal cancels the hard context. The soft context is always canceled when the hard context is.
+//
+// Both contexts carry a SignalCancelError cause. When the grace period elapses, the hard context's cause is a
+// GracePeriodError, which wraps the SignalCancelError of the first signal.
+func WithGracefulShutdown(parent context.Context, grace time.Duration, signals ...os.Signal) (soft, hard context.Context, cancel context.CancelFunc) {
+	var (
+		signalCh = make(chan os.Signal, 2)
+		done     = make(chan struct{})
+		stop     = make(chan struct{})
+	)
+
+	signal.Notify(signalCh, signals...)
+
+	hard, cancelHard := context.WithCancelCause(parent)
+	soft, cancelSoft := context.WithCancelCause(hard)
+
+	go func() {
+		defer close(done)
+		defer signal.Stop(signalCh)
+
+		var first os.Signal
+
+		select {
+		case first = <-signalCh:
+			cancelSoft(SignalCancelError{first})
+		case <-hard.Done():
+			return
+		case <-stop:
+			cancelHard(nil)
+			return
+		}
+
+		var expired <-chan time.Time
+		if grace > 0 {
+			timer := time.NewTimer(grace)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		select {
+		case sig := <-signalCh:
+			cancelHard(SignalCancelError{sig})
+		case <-expired:
+			cancelHard(GracePeriodError{Signal: first, Grace: grace})
+		case <-hard.Done():
+		case <-stop:
+			cancelHard(nil)
+		}
+	}()
+
+	var once sync.Once
+
+	cancel = func() {
+		once.Do(func() { close(stop) })
+		<-done
+	}
+
+	return soft, hard, cancel
+}
+
+// OnSignal calls handler for every occurrence of the given signals until ctx is done or stop is called. It is meant
+// for non-terminating signals such as SIGHUP or SIGUSR1, used to reload configuration or rotate logs. The handler runs
+// on its own goroutine, one signal at a time: signals received while it runs are coalesced. OnSignal starts
+// listening before it returns, such that no signal sent afterwards is missed. Stop waits for the handler to return, so
+// it must not be called from the handler itself.
+func OnSignal(ctx context.Context, handler func(os.Signal), signals ...os.Signal) (stop func()) {
+	var (
+		signalCh = make(chan os.Signal, 1)
+		done     = make(chan struct{})
+	)
+
+	signal.Notify(signalCh, signals...)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		defer close(done)
+		defer signal.Stop(signalCh)
+
+		for {
+			select {
+			case sig := <-signalCh:
+				handler(sig)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Signals returns an iterator over the occurrences of the given signals, which ends once ctx is done. The signals are
+// listened to from the start of the iteration until it ends, including when the loop is exited early.
+func Signals(ctx context.Context, signals ...os.Signal) iter.Seq[os.Signal] {
+	return func(yield func(os.Signal) bool) {
+		signalCh := make(chan os.Signal, 1)
+
+		signal.Notify(signalCh, signals...)
+		defer signal.Stop(signalCh)
+
+		for {
+			select {
+			case sig := <-signalCh:
+				if !yield(sig) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
 type SignalCancelError struct {
 	Signal os.Signal
 }
@@ -57,6 +179,22 @@ func (SignalCancelError) Unwrap() error {
 	return context.Canceled
 }
 
+// GracePeriodError is the cancelation cause of the hard context of WithGracefulShutdown once the grace period has
+// elapsed after the first signal.
+type GracePeriodError struct {
+	// Signal is the first signal received, which started the grace period.
+	Signal os.Signal
+	Grace  time.Duration
+}
+
+func (err GracePeriodError) Error() string {
+	return fmt.Sprintf("%v: grace period of %s elapsed", SignalCancelError{err.Signal}, err.Grace)
+}
+
+func (err GracePeriodError) Unwrap() error {
+	return SignalCancelError{err.Signal}
+}
+
 func SignalCause(ctx context.Context) os.Signal {
 	if sigErr := (SignalCancelError{}); errors.As(context.Cause(ctx), &sigErr) {
 		return sigErr.Signal
@@ -1,14 +1,17 @@
 package xcontext_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -93,6 +96,150 @@ func TestWithCancelation(t *testing.T) {
 		require.NoError(t, acceptance.Wait())
 		require.Equal(t, "context canceled: received signal: interrupt\n", stdout.String())
 	})
+
+	gracefulCMD := func(t *testing.T, env ...string) (cmd *exec.Cmd, lines *bufio.Scanner) {
+		cmd, _ = acceptanceCMD()
+		cmd.Stdout = nil
+		cmd.Env = append(cmd.Env, "GRACEFUL=true")
+		cmd.Env = append(cmd.Env, env...)
+
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(t, err)
+
+		require.NoError(t, cmd.Start())
+
+		// Give the process a chance to register the interceptors
+		time.Sleep(50 * time.Millisecond)
+
+		return cmd, bufio.NewScanner(stdout)
+	}
+
+	t.Run("graceful shutdown second signal", func(t *testing.T) {
+		acceptance, lines := gracefulCMD(t)
+
+		acceptance.Process.Signal(syscall.SIGINT)
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context canceled: received signal: interrupt", lines.Text())
+
+		acceptance.Process.Signal(syscall.SIGINT)
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context canceled: received signal: interrupt", lines.Text())
+
+		require.NoError(t, acceptance.Wait())
+	})
+
+	t.Run("graceful shutdown grace period", func(t *testing.T) {
+		acceptance, lines := gracefulCMD(t, "GRACE_PERIOD=50ms")
+
+		acceptance.Process.Signal(syscall.SIGINT)
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context canceled: received signal: interrupt", lines.Text())
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context canceled: received signal: interrupt: grace period of 50ms elapsed", lines.Text())
+
+		require.NoError(t, acceptance.Wait())
+	})
+
+	t.Run("graceful shutdown canceled", func(t *testing.T) {
+		acceptance, lines := gracefulCMD(t, "ROOT_TIMEOUT=10ms")
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context deadline exceeded", lines.Text())
+
+		require.True(t, lines.Scan())
+		require.Equal(t, "context deadline exceeded", lines.Text())
+
+		require.NoError(t, acceptance.Wait())
+	})
+}
+
+func TestWithGracefulShutdown(t *testing.T) {
+	soft, hard, cancel := xcontext.WithGracefulShutdown(context.Background(), time.Minute, syscall.SIGUSR1)
+	defer cancel()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	<-soft.Done()
+	require.Equal(t, syscall.SIGUSR1, xcontext.SignalCause(soft))
+	require.NoError(t, hard.Err())
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	<-hard.Done()
+	require.Equal(t, syscall.SIGUSR1, xcontext.SignalCause(hard))
+
+	cancel()
+	require.Equal(t, syscall.SIGUSR1, xcontext.SignalCause(hard))
+}
+
+func TestOnSignal(t *testing.T) {
+	received := make(chan os.Signal)
+
+	stop := xcontext.OnSignal(context.Background(), func(sig os.Signal) { received <- sig }, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	for _, sig := range []syscall.Signal{syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGUSR1} {
+		require.NoError(t, syscall.Kill(os.Getpid(), sig))
+		require.Equal(t, os.Signal(sig), <-received)
+	}
+
+	stop()
+	stop()
+}
+
+func TestOnSignalContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stop := xcontext.OnSignal(ctx, func(os.Signal) { t.Fatal("unexpected signal") }, syscall.SIGUSR1)
+	cancel()
+	stop()
+}
+
+func TestSignals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The guard keeps the default action of the signals from killing the test binary should they be sent before the
+	// iteration listens to them.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(guard)
+
+	var (
+		signals  = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+		next     atomic.Int32
+		received []os.Signal
+		done     = make(chan struct{})
+	)
+
+	// Each signal is sent until the iteration has seen it, as there is no telling when the iteration starts listening.
+	go func() {
+		defer close(done)
+		for i := next.Load(); int(i) < len(signals); i = next.Load() {
+			syscall.Kill(os.Getpid(), signals[i].(syscall.Signal))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for sig := range xcontext.Signals(ctx, syscall.SIGUSR1, syscall.SIGUSR2) {
+		received = append(received, sig)
+		if sig == signals[next.Load()] && int(next.Add(1)) == len(signals) {
+			break
+		}
+	}
+
+	<-done
+
+	require.Equal(t, signals, slices.Compact(received))
+
+	cancel()
+
+	for range xcontext.Signals(ctx, syscall.SIGUSR1) {
+		t.Fatal("iteration should end immediately once the context is done")
+	}
 }
 
 func TestSignalCause(t *testing.T) {
@@ -0,0 +1,113 @@
+package xcontext
+
+import (
+	"context"
+	"iter"
+	"math/rand/v2"
+	"time"
+)
+
+// Sleep pauses for the duration d, or until ctx is done. It returns nil once d has elapsed, or the cancelation cause
+// of ctx otherwise. Unlike a select on time.After, it releases its timer when interrupted.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// Ticker returns an iterator over the ticks of a time.Ticker of period d, which ends once ctx is done. The ticker runs
+// from the start of the iteration until it ends, including when the loop is exited early. As with time.Ticker, ticks
+// are dropped for slow loops. Whether the iteration ended because ctx is done is told by context.Cause(ctx).
+func Ticker(ctx context.Context, d time.Duration) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case tick := <-ticker.C:
+				if ctx.Err() != nil || !yield(tick) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls, including the first one. If zero, calls are retried until ctx is
+	// done.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry. Defaults to 100ms.
+	InitialDelay time.Duration
+	// MaxDelay bounds the delay between retries. Defaults to 30s.
+	MaxDelay time.Duration
+	// Multiplier is the factor applied to the delay after each retry. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction of each delay that is randomized, such that clients failing together do not retry
+	// together: with a jitter of 0.2 a delay of 1s becomes a random delay between 800ms and 1s. Defaults to 0.2. If
+	// negative, delays are not randomized.
+	Jitter float64
+	// Retryable reports whether an error is worth retrying. If nil, all errors are.
+	Retryable func(error) bool
+}
+
+func (policy *RetryPolicy) defaults() {
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = 100 * time.Millisecond
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = 30 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = 0.2
+	}
+	policy.Jitter = min(max(policy.Jitter, 0), 1)
+}
+
+// Retry calls fn until it succeeds, waiting between calls with an exponential backoff as described by policy. It
+// returns nil on success, the last error of fn once the attempts are exhausted or the error is not retryable, or the
+// cancelation cause of ctx if ctx is done before fn succeeds.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy.defaults()
+
+	delay := policy.InitialDelay
+
+	for attempt := 1; ; attempt++ {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if attempt == policy.MaxAttempts || (policy.Retryable != nil && !policy.Retryable(err)) {
+			return err
+		}
+
+		if err := Sleep(ctx, delay-time.Duration(policy.Jitter*rand.Float64()*float64(delay))); err != nil {
+			return err
+		}
+
+		delay = time.Duration(min(float64(delay)*policy.Multiplier, float64(policy.MaxDelay)))
+	}
+}
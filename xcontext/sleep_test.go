@@ -0,0 +1,129 @@
+package xcontext_test
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSleep(t *testing.T) {
+	start := time.Now()
+	require.NoError(t, xcontext.Sleep(context.Background(), 10*time.Millisecond))
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(xcontext.SignalCancelError{Signal: syscall.SIGTERM}) })
+
+	start = time.Now()
+	require.Equal(t, xcontext.SignalCancelError{Signal: syscall.SIGTERM}, xcontext.Sleep(ctx, time.Hour))
+	require.Less(t, time.Since(start), time.Second)
+
+	require.Equal(t, xcontext.SignalCancelError{Signal: syscall.SIGTERM}, xcontext.Sleep(ctx, 0))
+}
+
+func TestTicker(t *testing.T) {
+	t.Run("break", func(t *testing.T) {
+		var ticks int
+		for range xcontext.Ticker(context.Background(), time.Millisecond) {
+			if ticks++; ticks == 3 {
+				break
+			}
+		}
+		require.Equal(t, 3, ticks)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+		defer cancel()
+
+		var ticks int
+		for range xcontext.Ticker(ctx, 10*time.Millisecond) {
+			ticks++
+		}
+
+		require.Positive(t, ticks)
+		require.LessOrEqual(t, ticks, 2)
+		require.ErrorIs(t, context.Cause(ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	policy := xcontext.RetryPolicy{MaxAttempts: 4, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	t.Run("succeeds", func(t *testing.T) {
+		var attempts int
+		err := xcontext.Retry(context.Background(), policy, func(context.Context) error {
+			if attempts++; attempts < 3 {
+				return errors.New("unavailable")
+			}
+			return nil
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		var attempts int
+		err := xcontext.Retry(context.Background(), policy, func(context.Context) error {
+			attempts++
+			return errors.New("unavailable")
+		})
+
+		require.EqualError(t, err, "unavailable")
+		require.Equal(t, 4, attempts)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		errPermanent := errors.New("permanent")
+
+		policy := policy
+		policy.Retryable = func(err error) bool { return !errors.Is(err, errPermanent) }
+
+		var attempts int
+		err := xcontext.Retry(context.Background(), policy, func(context.Context) error {
+			attempts++
+			return errPermanent
+		})
+
+		require.Equal(t, errPermanent, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancelCause(context.Background())
+
+		var attempts int
+		err := xcontext.Retry(ctx, xcontext.RetryPolicy{InitialDelay: time.Hour}, func(context.Context) error {
+			attempts++
+			time.AfterFunc(10*time.Millisecond, func() { cancel(xcontext.SignalCancelError{Signal: syscall.SIGINT}) })
+			return errors.New("unavailable")
+		})
+
+		require.Equal(t, xcontext.SignalCancelError{Signal: syscall.SIGINT}, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		var calls []time.Time
+		err := xcontext.Retry(
+			context.Background(),
+			xcontext.RetryPolicy{MaxAttempts: 4, InitialDelay: 10 * time.Millisecond, Jitter: -1},
+			func(context.Context) error {
+				calls = append(calls, time.Now())
+				return errors.New("unavailable")
+			},
+		)
+		require.Error(t, err)
+		require.Len(t, calls, 4)
+
+		for i, expected := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond} {
+			require.GreaterOrEqual(t, calls[i+1].Sub(calls[i]), expected)
+		}
+	})
+}
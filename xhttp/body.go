@@ -0,0 +1,168 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/davidmdm/x/xio"
+)
+
+var (
+	ErrBodyTooLarge    = errors.New("request body too large")
+	ErrBodyReadStalled = errors.New("request body read stalled")
+)
+
+type BodyOptions struct {
+	// MaxSize is the maximum number of bytes that can be read from the request body. Zero means no limit.
+	MaxSize int64
+	// Rolling is the maximum duration a single read of the request body may wait for data. Zero means no limit.
+	Rolling time.Duration
+	// Clock provides the timers used to enforce the rolling timeout. Defaults to the system clock.
+	Clock Clock
+}
+
+// BodyHandler guards the inbound side of a request by limiting the size of its body and the time spent waiting for
+// it. When a limit trips, reading the body fails and the request context is canceled with ErrBodyTooLarge or
+// ErrBodyReadStalled as its cause. With a rolling timeout, reads of the body are also cancelable via the request
+// context, and a stalled read expires the connection's read deadline such that slow clients cannot tie up the handler.
+func BodyHandler(handler http.Handler, opts BodyOptions) http.Handler {
+	if opts.MaxSize <= 0 && opts.Rolling <= 0 {
+		return handler
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+
+		r = r.WithContext(ctx)
+
+		if r.Body != nil && r.Body != http.NoBody {
+			body := &bodyReader{
+				BodyOptions: opts,
+				ctx:         ctx,
+				cancel:      cancel,
+				body:        r.Body,
+				reader:      r.Body,
+				controller:  http.NewResponseController(w),
+			}
+			if opts.Rolling > 0 {
+				// Reading via xio costs a goroutine per read, which is only worth it when reads can stall.
+				body.reader = xio.NewReader(ctx, r.Body)
+			}
+			defer body.stop()
+
+			r.Body = body
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type bodyReader struct {
+	BodyOptions
+
+	ctx        context.Context
+	cancel     context.CancelCauseFunc
+	body       io.ReadCloser
+	reader     io.Reader
+	controller *http.ResponseController
+
+	mu      sync.Mutex
+	n       int64
+	timer   Timer
+	stopped bool
+}
+
+func (b *bodyReader) Read(p []byte) (n int, err error) {
+	if b.MaxSize > 0 {
+		if b.n > b.MaxSize {
+			return 0, ErrBodyTooLarge
+		}
+		// Read at most one byte past the limit to detect bodies that are too large.
+		if remaining := b.MaxSize - b.n + 1; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	b.arm()
+	n, err = b.reader.Read(p)
+	b.disarm()
+
+	b.n += int64(n)
+
+	if b.MaxSize > 0 && b.n > b.MaxSize {
+		n -= int(b.n - b.MaxSize)
+		b.cancel(fmt.Errorf("%w: %w", context.Canceled, ErrBodyTooLarge))
+		return n, ErrBodyTooLarge
+	}
+
+	if err != nil && errors.Is(context.Cause(b.ctx), ErrBodyReadStalled) {
+		return n, ErrBodyReadStalled
+	}
+
+	return n, err
+}
+
+func (b *bodyReader) Close() error {
+	b.stop()
+	return b.body.Close()
+}
+
+func (b *bodyReader) arm() {
+	if b.Rolling <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return
+	}
+	if b.timer == nil {
+		b.timer = b.Clock.AfterFunc(b.Rolling, b.stall)
+		return
+	}
+	b.timer.Reset(b.Rolling)
+}
+
+func (b *bodyReader) disarm() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+func (b *bodyReader) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+func (b *bodyReader) stall() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return
+	}
+
+	b.cancel(fmt.Errorf("%w: %w", context.Canceled, ErrBodyReadStalled))
+
+	// Release the blocked read of the underlying connection.
+	b.controller.SetReadDeadline(time.Now())
+}
@@ -0,0 +1,125 @@
+package xhttp_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyHandler(t *testing.T) {
+	type result struct {
+		Body  string
+		Err   error
+		Cause error
+	}
+
+	cases := []struct {
+		Name string
+		Opts xhttp.BodyOptions
+		Body func(*testing.T, io.Writer)
+
+		ExpectedBody  string
+		ExpectedErr   error
+		ExpectedCause error
+	}{
+		{
+			Name: "within limits",
+			Opts: xhttp.BodyOptions{MaxSize: 11, Rolling: 50 * time.Millisecond},
+			Body: func(t *testing.T, w io.Writer) {
+				io.WriteString(w, "hello world")
+			},
+			ExpectedBody: "hello world",
+		},
+		{
+			Name: "too large",
+			Opts: xhttp.BodyOptions{MaxSize: 5},
+			Body: func(t *testing.T, w io.Writer) {
+				io.WriteString(w, "hello world")
+			},
+			ExpectedBody:  "hello",
+			ExpectedErr:   xhttp.ErrBodyTooLarge,
+			ExpectedCause: xhttp.ErrBodyTooLarge,
+		},
+		{
+			Name: "stalled",
+			Opts: xhttp.BodyOptions{Rolling: 20 * time.Millisecond},
+			Body: func(t *testing.T, w io.Writer) {
+				io.WriteString(w, "hello")
+				time.Sleep(200 * time.Millisecond)
+				io.WriteString(w, " world")
+			},
+			ExpectedBody:  "hello",
+			ExpectedErr:   xhttp.ErrBodyReadStalled,
+			ExpectedCause: xhttp.ErrBodyReadStalled,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			results := make(chan result, 1)
+
+			handler := xhttp.BodyHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, err := io.ReadAll(r.Body)
+					results <- result{
+						Body:  string(body),
+						Err:   err,
+						Cause: context.Cause(r.Context()),
+					}
+				}),
+				tc.Opts,
+			)
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			pr, pw := io.Pipe()
+			go func() {
+				tc.Body(t, pw)
+				pw.Close()
+			}()
+
+			req, err := http.NewRequest("POST", server.URL, pr)
+			require.NoError(t, err)
+
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+
+			res := <-results
+
+			require.Equal(t, tc.ExpectedBody, res.Body)
+
+			if tc.ExpectedErr == nil {
+				require.NoError(t, res.Err)
+				require.NoError(t, res.Cause)
+				return
+			}
+
+			require.ErrorIs(t, res.Err, tc.ExpectedErr)
+			require.ErrorIs(t, res.Cause, tc.ExpectedCause)
+			require.ErrorIs(t, res.Cause, context.Canceled)
+		})
+	}
+}
+
+func TestBodyHandlerNoLimits(t *testing.T) {
+	handler := xhttp.BodyHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(w, r.Body)
+		}),
+		xhttp.BodyOptions{},
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("echo")))
+
+	require.Equal(t, "echo", w.Body.String())
+}
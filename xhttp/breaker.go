@@ -0,0 +1,308 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by the CircuitBreaker when a request is rejected because the circuit of its host is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(state))
+	}
+}
+
+type BreakerOptions struct {
+	// Transport is the underlying round tripper. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// FailureRate is the ratio of failed requests within the Window at which the circuit opens. Defaults to 0.5.
+	FailureRate float64
+	// MinRequests is the number of requests required within the Window before the FailureRate is considered.
+	// Defaults to 10.
+	MinRequests int
+	// Window is the duration over which requests are counted. Defaults to 10 seconds.
+	Window time.Duration
+	// Cooldown is the duration the circuit stays open before letting probe requests through. Defaults to 5 seconds.
+	Cooldown time.Duration
+	// HalfOpenProbes is the number of concurrent probe requests allowed while half-open. The circuit closes once
+	// as many probes have succeeded, and opens again as soon as one fails. Defaults to 1.
+	HalfOpenProbes int
+	// IsFailure reports whether a round trip counts as a failure. Defaults to transport errors and 5xx responses.
+	// Round trips canceled by the caller are never counted, as they say nothing about the health of the host.
+	IsFailure func(*http.Response, error) bool
+	// Key returns the key of the circuit a request belongs to. Defaults to the request's host.
+	Key func(*http.Request) string
+	// OnStateChange, if set, is called whenever the circuit of a key changes state.
+	OnStateChange func(key string, from, to CircuitState)
+	// Clock provides the time used for the Window and the Cooldown. Defaults to the system clock.
+	Clock Clock
+}
+
+// CircuitBreaker is an http.RoundTripper that tracks the health of each host it sends requests to. When the rate of
+// failures to a host exceeds the FailureRate, its circuit opens and requests fail fast with ErrCircuitOpen. After the
+// Cooldown, the circuit is half-open and a limited number of probe requests decide whether it closes again.
+type CircuitBreaker struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+var _ http.RoundTripper = (*CircuitBreaker)(nil)
+
+func NewCircuitBreaker(opts BreakerOptions) *CircuitBreaker {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.FailureRate <= 0 {
+		opts.FailureRate = 0.5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 5 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = defaultIsFailure
+	}
+	if opts.Key == nil {
+		opts.Key = func(r *http.Request) string { return r.URL.Host }
+	}
+	if opts.OnStateChange == nil {
+		opts.OnStateChange = func(string, CircuitState, CircuitState) {}
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	return &CircuitBreaker{
+		opts:     opts,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
+
+func (cb *CircuitBreaker) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := cb.opts.Key(r)
+
+	probe, err := cb.allow(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cb.opts.Transport.RoundTrip(r)
+
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled)) {
+		cb.release(key, probe)
+		return resp, err
+	}
+
+	cb.record(key, probe, cb.opts.IsFailure(resp, err))
+
+	return resp, err
+}
+
+// State returns the current state of the circuit for key.
+func (cb *CircuitBreaker) State(key string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c, ok := cb.circuits[key]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+type circuit struct {
+	state     CircuitState
+	openedAt  time.Time
+	probes    int
+	successes int
+	window    window
+}
+
+type stateChange struct {
+	from, to CircuitState
+}
+
+func (cb *CircuitBreaker) circuit(key string) *circuit {
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{window: newWindow(cb.opts.Window)}
+		cb.circuits[key] = c
+	}
+	return c
+}
+
+func (cb *CircuitBreaker) allow(key string) (probe bool, err error) {
+	var changes []stateChange
+	defer func() { cb.notify(key, changes) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(key)
+
+	now := cb.opts.Clock.Now()
+
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= cb.opts.Cooldown {
+		changes = append(changes, c.transition(CircuitHalfOpen, now))
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		return false, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+	case CircuitHalfOpen:
+		if c.probes >= cb.opts.HalfOpenProbes {
+			return false, fmt.Errorf("%w: %s: probe limit reached", ErrCircuitOpen, key)
+		}
+		c.probes++
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (cb *CircuitBreaker) record(key string, probe, failure bool) {
+	var changes []stateChange
+	defer func() { cb.notify(key, changes) }()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(key)
+	now := cb.opts.Clock.Now()
+
+	if probe {
+		if c.state != CircuitHalfOpen {
+			return
+		}
+		c.probes--
+		if failure {
+			changes = append(changes, c.transition(CircuitOpen, now))
+			return
+		}
+		if c.successes++; c.successes >= cb.opts.HalfOpenProbes {
+			changes = append(changes, c.transition(CircuitClosed, now))
+		}
+		return
+	}
+
+	if c.state != CircuitClosed {
+		return
+	}
+
+	successes, failures := c.window.add(now, failure)
+	if total := successes + failures; total >= cb.opts.MinRequests && float64(failures)/float64(total) >= cb.opts.FailureRate {
+		changes = append(changes, c.transition(CircuitOpen, now))
+	}
+}
+
+// release frees the probe slot of a canceled round trip without deciding the outcome of the probe.
+func (cb *CircuitBreaker) release(key string, probe bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if c := cb.circuit(key); probe && c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+func (cb *CircuitBreaker) notify(key string, changes []stateChange) {
+	for _, change := range changes {
+		cb.opts.OnStateChange(key, change.from, change.to)
+	}
+}
+
+func (c *circuit) transition(state CircuitState, now time.Time) stateChange {
+	change := stateChange{from: c.state, to: state}
+
+	c.state = state
+	c.probes = 0
+	c.successes = 0
+
+	switch state {
+	case CircuitOpen:
+		c.openedAt = now
+	case CircuitClosed:
+		c.window.reset()
+	}
+
+	return change
+}
+
+// window counts successes and failures over a rolling duration using a fixed number of buckets.
+type window struct {
+	size    time.Duration
+	buckets [10]bucket
+}
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+func newWindow(size time.Duration) window {
+	return window{size: size}
+}
+
+func (w *window) add(now time.Time, failure bool) (successes, failures int) {
+	width := max(w.size/time.Duration(len(w.buckets)), 1)
+	start := now.Truncate(width)
+
+	b := &w.buckets[(start.UnixNano()/int64(width))%int64(len(w.buckets))]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+
+	if failure {
+		b.failures++
+	} else {
+		b.successes++
+	}
+
+	for _, b := range w.buckets {
+		if now.Sub(b.start) < w.size {
+			successes += b.successes
+			failures += b.failures
+		}
+	}
+
+	return
+}
+
+func (w *window) reset() {
+	w.buckets = [10]bucket{}
+}
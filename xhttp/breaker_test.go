@@ -0,0 +1,213 @@
+package xhttp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/davidmdm/x/xhttp/xhttptest"
+	"github.com/stretchr/testify/require"
+)
+
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return fn(r) }
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		healthy = map[string]bool{"a.test": true, "b.test": true}
+		changes []string
+		clock   = xhttptest.NewClock(time.Now())
+	)
+
+	setHealthy := func(host string, value bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		healthy[host] = value
+	}
+
+	transport := RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !healthy[r.URL.Host] {
+			return nil, errors.New("connection refused")
+		}
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(200)
+		return rec.Result(), nil
+	})
+
+	breaker := xhttp.NewCircuitBreaker(xhttp.BreakerOptions{
+		Transport:      transport,
+		FailureRate:    0.5,
+		MinRequests:    4,
+		Window:         time.Minute,
+		Cooldown:       20 * time.Millisecond,
+		HalfOpenProbes: 2,
+		Clock:          clock,
+		OnStateChange: func(key string, from, to xhttp.CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, key+": "+from.String()+" -> "+to.String())
+		},
+	})
+
+	client := &http.Client{Transport: breaker}
+
+	get := func(host string) error {
+		resp, err := client.Get("http://" + host)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	// Two successes followed by two failures reaches the failure rate once MinRequests is met.
+	require.NoError(t, get("a.test"))
+	require.NoError(t, get("a.test"))
+
+	setHealthy("a.test", false)
+
+	require.Error(t, get("a.test"))
+	require.Equal(t, xhttp.CircuitClosed, breaker.State("a.test"))
+	require.Error(t, get("a.test"))
+	require.Equal(t, xhttp.CircuitOpen, breaker.State("a.test"))
+
+	// Open circuits fail fast without reaching the transport, and are tracked per host.
+	require.ErrorIs(t, get("a.test"), xhttp.ErrCircuitOpen)
+	require.NoError(t, get("b.test"))
+	require.Equal(t, xhttp.CircuitClosed, breaker.State("b.test"))
+
+	// After the cooldown a failed probe opens the circuit again.
+	clock.Advance(30 * time.Millisecond)
+
+	err := get("a.test")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, xhttp.ErrCircuitOpen)
+	require.Equal(t, xhttp.CircuitOpen, breaker.State("a.test"))
+
+	// Once healthy, enough successful probes close the circuit.
+	clock.Advance(30 * time.Millisecond)
+	setHealthy("a.test", true)
+
+	require.NoError(t, get("a.test"))
+	require.Equal(t, xhttp.CircuitHalfOpen, breaker.State("a.test"))
+	require.NoError(t, get("a.test"))
+	require.Equal(t, xhttp.CircuitClosed, breaker.State("a.test"))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(
+		t,
+		[]string{
+			"a.test: closed -> open",
+			"a.test: open -> half-open",
+			"a.test: half-open -> open",
+			"a.test: open -> half-open",
+			"a.test: half-open -> closed",
+		},
+		changes,
+	)
+}
+
+func TestCircuitBreakerProbeLimit(t *testing.T) {
+	release := make(chan struct{})
+	calls := make(chan struct{}, 10)
+
+	fail := true
+	clock := xhttptest.NewClock(time.Now())
+
+	breaker := xhttp.NewCircuitBreaker(xhttp.BreakerOptions{
+		Transport: RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls <- struct{}{}
+			if fail {
+				return nil, errors.New("boom")
+			}
+			<-release
+			rec := httptest.NewRecorder()
+			return rec.Result(), nil
+		}),
+		MinRequests: 1,
+		Cooldown:    10 * time.Millisecond,
+		Clock:       clock,
+	})
+
+	req := httptest.NewRequest("GET", "http://c.test", nil)
+
+	_, err := breaker.RoundTrip(req)
+	require.Error(t, err)
+	<-calls
+	require.Equal(t, xhttp.CircuitOpen, breaker.State("c.test"))
+
+	fail = false
+	clock.Advance(20 * time.Millisecond)
+
+	probeDone := make(chan error, 1)
+	go func() {
+		_, err := breaker.RoundTrip(req)
+		probeDone <- err
+	}()
+	<-calls
+
+	// The single probe is in flight: further requests are rejected.
+	_, err = breaker.RoundTrip(req)
+	require.ErrorIs(t, err, xhttp.ErrCircuitOpen)
+
+	close(release)
+	require.NoError(t, <-probeDone)
+	require.Equal(t, xhttp.CircuitClosed, breaker.State("c.test"))
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	clock := xhttptest.NewClock(time.Now())
+
+	breaker := xhttp.NewCircuitBreaker(xhttp.BreakerOptions{
+		Transport: RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err := r.Context().Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("connection refused")
+		}),
+		MinRequests: 2,
+		Cooldown:    10 * time.Millisecond,
+		Clock:       clock,
+	})
+
+	req := httptest.NewRequest("GET", "http://d.test", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := req.WithContext(ctx)
+
+	// Canceled round trips are left out of the window.
+	for range 3 {
+		_, err := breaker.RoundTrip(canceled)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.Equal(t, xhttp.CircuitClosed, breaker.State("d.test"))
+
+	for range 2 {
+		_, err := breaker.RoundTrip(req)
+		require.Error(t, err)
+	}
+	require.Equal(t, xhttp.CircuitOpen, breaker.State("d.test"))
+
+	clock.Advance(10 * time.Millisecond)
+
+	// A canceled probe frees its slot without closing the circuit.
+	_, err := breaker.RoundTrip(canceled)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, xhttp.CircuitHalfOpen, breaker.State("d.test"))
+
+	_, err = breaker.RoundTrip(req)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, xhttp.ErrCircuitOpen)
+	require.Equal(t, xhttp.CircuitOpen, breaker.State("d.test"))
+}
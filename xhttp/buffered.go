@@ -0,0 +1,144 @@
+package xhttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrBufferExceeded is returned by writes in buffered mode once the response exceeds the configured BufferSize.
+var ErrBufferExceeded = errors.New("response exceeded max buffer size")
+
+// bufferedTimeoutHandler implements the buffered mode of the TimeoutHandler. The handler's response is held in memory
+// until the handler returns. If the initial timeout is reached first, the timeout handler is served instead and the
+// client never sees a partial response.
+func bufferedTimeoutHandler(handler http.Handler, opts TimeoutOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancelCause(r.Context())
+		defer cancel(nil)
+
+		r = r.WithContext(ctx)
+
+		var result error
+		defer func() { opts.OnComplete(r, result) }()
+
+		done := make(chan int, 2)
+
+		bw := bufferedWriter{
+			max:     opts.BufferSize,
+			cancel:  cancel,
+			headers: make(http.Header),
+		}
+
+		defer opts.Clock.AfterFunc(opts.Initial, func() { done <- timeout }).Stop()
+
+		panics, returned := make(chan any), make(chan struct{})
+		defer close(returned)
+
+		spawn(handler, &bw, r, panics, returned, func() { done <- writing })
+
+		var state int
+		select {
+		case state = <-done:
+		case value := <-panics:
+			bw.finish(writing)
+			result, _ = value.(error)
+			panic(value)
+		}
+
+		if state := bw.finish(state); state == timeout {
+			result = ErrTimeoutBeforeWrite
+			cancel(fmt.Errorf("%w: %w", context.Canceled, ErrTimeoutBeforeWrite))
+			opts.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		if bw.exceeded {
+			result = ErrBufferExceeded
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		for key, values := range bw.headers {
+			w.Header()[key] = values
+		}
+
+		if opts.Rolling > 0 {
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(opts.Rolling))
+		}
+
+		w.WriteHeader(bw.status)
+		w.Write(bw.buf.Bytes())
+	})
+}
+
+type bufferedWriter struct {
+	max    int
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	state    int
+	exceeded bool
+	status   int
+	headers  http.Header
+	buf      bytes.Buffer
+}
+
+// finish records the final state of the writer such that any subsequent writes fail. The first state to be recorded wins.
+func (w *bufferedWriter) finish(state int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state == pending {
+		w.state = state
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.state
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.headers
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != pending || w.status != 0 || status < 200 {
+		return
+	}
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch {
+	case w.state == timeout:
+		return 0, ErrTimeoutBeforeWrite
+	case w.state != pending:
+		return 0, io.ErrClosedPipe
+	case w.exceeded:
+		return 0, ErrBufferExceeded
+	}
+
+	if w.buf.Len()+len(data) > w.max {
+		w.exceeded = true
+		w.cancel(fmt.Errorf("%w: %w", context.Canceled, ErrBufferExceeded))
+		return 0, ErrBufferExceeded
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.buf.Write(data)
+}
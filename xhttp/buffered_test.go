@@ -0,0 +1,131 @@
+package xhttp_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutHandlerBuffered(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Handler func(http.ResponseWriter, *http.Request) error
+		Opts    xhttp.TimeoutOptions
+
+		ExpectedServeError func(*testing.T, error)
+
+		ExpectedStatus int
+		ExpectedHeader map[string]string
+		ExpectedBody   string
+	}{
+		{
+			Name: "happy",
+			Handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				if _, err := io.WriteString(w, `{"hello":`); err != nil {
+					return err
+				}
+				_, err := io.WriteString(w, `"world"}`)
+				return err
+			},
+			Opts: xhttp.TimeoutOptions{
+				Initial:    50 * time.Millisecond,
+				BufferSize: 1024,
+			},
+			ExpectedStatus: 201,
+			ExpectedHeader: map[string]string{
+				"Content-Type": "application/json",
+			},
+			ExpectedBody: `{"hello":"world"}`,
+		},
+		{
+			Name: "timeout after partial write",
+			Handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Test-Dirty-Write", "true")
+				if _, err := io.WriteString(w, `{"hello":`); err != nil {
+					return err
+				}
+				<-r.Context().Done()
+				if _, err := io.WriteString(w, `"world"}`); err != nil {
+					return err
+				}
+				return context.Cause(r.Context())
+			},
+			Opts: xhttp.TimeoutOptions{
+				Initial:    10 * time.Millisecond,
+				BufferSize: 1024,
+			},
+			ExpectedServeError: func(t *testing.T, err error) {
+				require.EqualError(t, err, "request timeout reached before write")
+			},
+			ExpectedStatus: 503,
+			ExpectedHeader: map[string]string{
+				"Content-Type":     "text/html; charset=utf-8",
+				"Test-Dirty-Write": "",
+			},
+			ExpectedBody: "<html><body>Service Unavailable</body></html>",
+		},
+		{
+			Name: "buffer exceeded",
+			Handler: func(w http.ResponseWriter, r *http.Request) error {
+				if _, err := io.WriteString(w, strings.Repeat("a", 8)); err != nil {
+					return err
+				}
+				_, err := io.WriteString(w, strings.Repeat("b", 8))
+				require.ErrorIs(t, context.Cause(r.Context()), xhttp.ErrBufferExceeded)
+				return err
+			},
+			Opts: xhttp.TimeoutOptions{
+				Initial:    50 * time.Millisecond,
+				BufferSize: 10,
+			},
+			ExpectedServeError: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, xhttp.ErrBufferExceeded)
+			},
+			ExpectedStatus: 500,
+			ExpectedBody:   "Internal Server Error\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			serveErr := make(chan error, 1)
+
+			server := httptest.NewServer(xhttp.TimeoutHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					serveErr <- tc.Handler(w, r)
+				}),
+				tc.Opts,
+			))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+
+			for key, value := range tc.ExpectedHeader {
+				require.Equal(t, value, resp.Header.Get(key), "unexpected value for header %s", key)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedBody, string(body))
+
+			if tc.ExpectedServeError != nil {
+				tc.ExpectedServeError(t, <-serveErr)
+				return
+			}
+			require.NoError(t, <-serveErr)
+		})
+	}
+}
@@ -0,0 +1,580 @@
+package xhttp
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a response stored by the cache.
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Vary holds the values of the request headers nominated by the Vary header of the response.
+	Vary http.Header
+	// Created is the estimated time at which the response was generated by the origin, accounting for its Age.
+	Created time.Time
+}
+
+// CacheStorage is the storage used by the CacheTransport and CacheHandler. Implementations must be safe for
+// concurrent use. Stored entries must not be modified.
+type CacheStorage interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory CacheStorage evicting the least recently used entries once full.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+var _ CacheStorage = (*MemoryCache)(nil)
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns a MemoryCache holding at most capacity entries.
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: max(capacity, 1),
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (cache *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	elem, ok := cache.items[key]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(elem)
+	return elem.Value.(memoryCacheItem).entry, true
+}
+
+func (cache *MemoryCache) Set(key string, entry *CacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		elem.Value = memoryCacheItem{key: key, entry: entry}
+		cache.order.MoveToFront(elem)
+		return
+	}
+
+	cache.items[key] = cache.order.PushFront(memoryCacheItem{key: key, entry: entry})
+
+	for cache.order.Len() > cache.capacity {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.items, oldest.Value.(memoryCacheItem).key)
+	}
+}
+
+func (cache *MemoryCache) Delete(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if elem, ok := cache.items[key]; ok {
+		cache.order.Remove(elem)
+		delete(cache.items, key)
+	}
+}
+
+func (cache *MemoryCache) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.order.Len()
+}
+
+type CacheOptions struct {
+	// Storage stores the cached responses. Defaults to a MemoryCache of 1024 entries.
+	Storage CacheStorage
+	// MaxEntrySize is the maximum size of a response body that can be cached. Defaults to 1MB.
+	MaxEntrySize int
+	// Transport is the underlying round tripper of a CacheTransport. Defaults to http.DefaultTransport.
+	// It is not used by the CacheHandler.
+	Transport http.RoundTripper
+}
+
+func (opts *CacheOptions) defaults() {
+	if opts.Storage == nil {
+		opts.Storage = NewMemoryCache(1024)
+	}
+	if opts.MaxEntrySize <= 0 {
+		opts.MaxEntrySize = 1 << 20
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+}
+
+// CacheTransport is an http.RoundTripper implementing a private HTTP cache as described by RFC 9111. Fresh responses
+// to GET requests are served from the cache. Stale responses with validators are revalidated using If-None-Match and
+// If-Modified-Since conditional requests. Cache-Control directives and the Vary header of responses are honored, and
+// successful unsafe requests invalidate the cached response of their URL. Responses carry a Cache-Status header
+// (RFC 9211) describing how the cache handled them.
+type CacheTransport struct {
+	opts CacheOptions
+}
+
+var _ http.RoundTripper = (*CacheTransport)(nil)
+
+func NewCacheTransport(opts CacheOptions) *CacheTransport {
+	opts.defaults()
+	return &CacheTransport{opts: opts}
+}
+
+func (transport *CacheTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	key := cacheKey(r)
+
+	if r.Method != http.MethodGet {
+		resp, err := transport.opts.Transport.RoundTrip(r)
+		if err == nil && !isSafeMethod(r.Method) && resp.StatusCode < 400 {
+			transport.opts.Storage.Delete(key)
+		}
+		return resp, err
+	}
+
+	requestDirectives := parseCacheControl(r.Header)
+	if _, ok := requestDirectives["no-store"]; ok {
+		return transport.opts.Transport.RoundTrip(r)
+	}
+
+	now := time.Now()
+
+	entry, ok := transport.opts.Storage.Get(key)
+	if ok && !entry.matches(r) {
+		entry, ok = nil, false
+	}
+
+	if ok && entry.servable(requestDirectives, now, false) {
+		return entry.response(r, now, "hit"), nil
+	}
+
+	req := r
+	revalidating := ok && entry.hasValidators() && !isConditional(r)
+	if revalidating {
+		req = r.Clone(r.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := transport.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if revalidating && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+
+		updated := entry.refresh(resp.Header, now)
+		transport.opts.Storage.Set(key, updated)
+
+		return updated.response(r, now, "fwd=stale; fwd-status=304"), nil
+	}
+
+	resp.Header.Set("Cache-Status", "xhttp; fwd="+cacheForward(ok))
+
+	if !cacheable(r, resp.StatusCode, resp.Header, false) {
+		return resp, nil
+	}
+
+	resp.Body = &cacheBody{
+		ReadCloser: resp.Body,
+		max:        transport.opts.MaxEntrySize,
+		store: func(body []byte) {
+			transport.opts.Storage.Set(key, newCacheEntry(r, resp.StatusCode, resp.Header, body, now))
+		},
+	}
+
+	return resp, nil
+}
+
+// CacheHandler implements a shared HTTP cache as described by RFC 9111 in front of handler. Fresh responses to GET
+// requests are served from the cache without calling the handler, and conditional requests matching the ETag of a
+// cached response are answered with 304 Not Modified. Stale responses are regenerated by the handler. Responses that
+// set cookies are never stored.
+func CacheHandler(handler http.Handler, opts CacheOptions) http.Handler {
+	opts.defaults()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := cacheKey(r)
+
+		if r.Method != http.MethodGet {
+			rw := NewRecordingWriter(w)
+			handler.ServeHTTP(rw, r)
+			if !isSafeMethod(r.Method) && rw.Recording().Status < 400 {
+				opts.Storage.Delete(key)
+			}
+			return
+		}
+
+		requestDirectives := parseCacheControl(r.Header)
+
+		now := time.Now()
+
+		if entry, ok := opts.Storage.Get(key); ok && entry.matches(r) && entry.servable(requestDirectives, now, true) {
+			entry.serve(w, r, now)
+			return
+		}
+
+		if _, ok := requestDirectives["no-store"]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &cacheWriter{ResponseWriter: w, max: opts.MaxEntrySize}
+		handler.ServeHTTP(cw, r)
+
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.overflow || !cacheable(r, cw.status, w.Header(), true) {
+			return
+		}
+
+		opts.Storage.Set(key, newCacheEntry(r, cw.status, w.Header(), cw.buf.Bytes(), now))
+	})
+}
+
+// Cache is the middleware form of CacheHandler.
+func Cache(opts CacheOptions) Middleware {
+	return Named("xhttp.Cache", func(handler http.Handler) http.Handler {
+		return CacheHandler(handler, opts)
+	})
+}
+
+func cacheKey(r *http.Request) string {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+func cacheForward(found bool) string {
+	if found {
+		return "stale"
+	}
+	return "miss"
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isConditional(r *http.Request) bool {
+	for _, key := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if r.Header.Get(key) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCacheControl parses the Cache-Control directives of header. Directive names are lowercased and values unquoted.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, true
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+var cacheableStatus = []int{200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// cacheable reports whether a response can be stored. Only responses with explicit freshness or validators are stored,
+// as heuristic freshness is not supported.
+func cacheable(r *http.Request, status int, header http.Header, shared bool) bool {
+	if !slices.Contains(cacheableStatus, status) {
+		return false
+	}
+	if slices.Contains(header.Values("Vary"), "*") {
+		return false
+	}
+
+	directives := parseCacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return false
+	}
+
+	_, public := directives["public"]
+	_, sMaxAge := directives["s-maxage"]
+
+	if shared {
+		if _, ok := directives["private"]; ok {
+			return false
+		}
+		// Cookies set for one client must never be replayed to another.
+		if header.Get("Set-Cookie") != "" {
+			return false
+		}
+		if r.Header.Get("Authorization") != "" && !public && !sMaxAge {
+			return false
+		}
+	}
+
+	_, maxAge := directives["max-age"]
+	return maxAge || sMaxAge || public || header.Get("Expires") != "" || header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+}
+
+func newCacheEntry(r *http.Request, status int, header http.Header, body []byte, now time.Time) *CacheEntry {
+	entry := &CacheEntry{
+		StatusCode: status,
+		Header:     header.Clone(),
+		Body:       bytes.Clone(body),
+		Vary:       make(http.Header),
+		Created:    now,
+	}
+
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+		entry.Created = now.Add(-time.Duration(age) * time.Second)
+	}
+
+	for _, name := range varyHeaders(header) {
+		entry.Vary[name] = r.Header.Values(name)
+	}
+
+	return entry
+}
+
+func varyHeaders(header http.Header) (names []string) {
+	for _, value := range header.Values("Vary") {
+		for name := range strings.SplitSeq(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return
+}
+
+// matches reports whether the request nominates the same header values as the request that produced the entry.
+func (entry *CacheEntry) matches(r *http.Request) bool {
+	for name, values := range entry.Vary {
+		if !slices.Equal(values, r.Header.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (entry *CacheEntry) hasValidators() bool {
+	return entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != ""
+}
+
+func (entry *CacheEntry) age(now time.Time) time.Duration {
+	return max(now.Sub(entry.Created), 0)
+}
+
+func (entry *CacheEntry) lifetime(shared bool) time.Duration {
+	directives := parseCacheControl(entry.Header)
+
+	if shared {
+		if value, ok := directiveSeconds(directives, "s-maxage"); ok {
+			return value
+		}
+	}
+	if value, ok := directiveSeconds(directives, "max-age"); ok {
+		return value
+	}
+	if expires, err := http.ParseTime(entry.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(entry.Header.Get("Date"))
+		if err != nil {
+			date = entry.Created
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+// servable reports whether the entry can be served without contacting the origin.
+func (entry *CacheEntry) servable(requestDirectives map[string]string, now time.Time, shared bool) bool {
+	if _, ok := requestDirectives["no-cache"]; ok {
+		return false
+	}
+	if _, ok := parseCacheControl(entry.Header)["no-cache"]; ok {
+		return false
+	}
+
+	age := entry.age(now)
+	if maxAge, ok := directiveSeconds(requestDirectives, "max-age"); ok && age > maxAge {
+		return false
+	}
+
+	return age < entry.lifetime(shared)
+}
+
+// refresh returns a copy of the entry updated with the headers of a 304 Not Modified response.
+func (entry *CacheEntry) refresh(header http.Header, now time.Time) *CacheEntry {
+	refreshed := *entry
+	refreshed.Header = entry.Header.Clone()
+	refreshed.Created = now
+
+	for key, values := range header {
+		switch key {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		refreshed.Header[key] = values
+	}
+
+	return &refreshed
+}
+
+func (entry *CacheEntry) header(now time.Time, status string) http.Header {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(entry.age(now).Seconds())))
+	header.Set("Cache-Status", "xhttp; "+status)
+	return header
+}
+
+func (entry *CacheEntry) response(r *http.Request, now time.Time, status string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.header(now, status),
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       r,
+	}
+}
+
+func (entry *CacheEntry) serve(w http.ResponseWriter, r *http.Request, now time.Time) {
+	for key, values := range entry.header(now, "hit") {
+		w.Header()[key] = values
+	}
+
+	if etag := strings.TrimPrefix(entry.Header.Get("ETag"), "W/"); etag != "" && slices.Contains(splitETags(r.Header.Get("If-None-Match")), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.Body)))
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
+}
+
+// splitETags splits the entity tags of an If-None-Match header, which are compared weakly.
+func splitETags(value string) (etags []string) {
+	for etag := range strings.SplitSeq(value, ",") {
+		if etag = strings.TrimSpace(etag); etag != "" {
+			etags = append(etags, strings.TrimPrefix(etag, "W/"))
+		}
+	}
+	return
+}
+
+// cacheBody stores the response body once it has been read in full.
+type cacheBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+	stored   bool
+	store    func([]byte)
+}
+
+func (body *cacheBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+
+	if !body.overflow {
+		if body.buf.Len()+n > body.max {
+			body.overflow = true
+			body.buf = bytes.Buffer{}
+		} else {
+			body.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && !body.overflow && !body.stored {
+		body.stored = true
+		body.store(body.buf.Bytes())
+	}
+
+	return n, err
+}
+
+// cacheWriter passes the response through to the client while keeping a copy of its body.
+type cacheWriter struct {
+	http.ResponseWriter
+	status   int
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(data) > w.max {
+			w.overflow = true
+			w.buf = bytes.Buffer{}
+		} else {
+			w.buf.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap satisfies the implicit http.rwUnwrapper interface.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,219 @@
+package xhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var count atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fresh", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		io.WriteString(w, "fresh "+strconv.Itoa(int(count.Add(1))))
+	})
+	mux.HandleFunc("/etag", func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "etag body")
+	})
+	mux.HandleFunc("/vary", func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		io.WriteString(w, "hello in "+r.Header.Get("Accept-Language"))
+	})
+	mux.HandleFunc("/no-store", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		io.WriteString(w, "no-store "+strconv.Itoa(int(count.Add(1))))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &http.Client{Transport: xhttp.NewCacheTransport(xhttp.CacheOptions{})}
+
+	do := func(method, path string, header map[string]string) (string, string, int) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return string(body), resp.Header.Get("Cache-Status"), resp.StatusCode
+	}
+
+	t.Run("fresh responses are served from cache", func(t *testing.T) {
+		count.Store(0)
+
+		body, status, _ := do("GET", "/fresh", nil)
+		require.Equal(t, "fresh 1", body)
+		require.Equal(t, "xhttp; fwd=miss", status)
+
+		body, status, _ = do("GET", "/fresh", nil)
+		require.Equal(t, "fresh 1", body)
+		require.Equal(t, "xhttp; hit", status)
+
+		body, _, _ = do("GET", "/fresh", map[string]string{"Cache-Control": "no-cache"})
+		require.Equal(t, "fresh 2", body)
+	})
+
+	t.Run("unsafe requests invalidate", func(t *testing.T) {
+		count.Store(0)
+
+		body, _, _ := do("GET", "/fresh", nil)
+		require.Equal(t, "fresh 2", body)
+
+		do("POST", "/fresh", nil)
+
+		body, status, _ := do("GET", "/fresh", nil)
+		require.Equal(t, "fresh 2", body)
+		require.Equal(t, "xhttp; fwd=miss", status)
+	})
+
+	t.Run("stale responses are revalidated", func(t *testing.T) {
+		count.Store(0)
+
+		body, _, _ := do("GET", "/etag", nil)
+		require.Equal(t, "etag body", body)
+
+		body, status, code := do("GET", "/etag", nil)
+		require.Equal(t, 200, code)
+		require.Equal(t, "etag body", body)
+		require.Equal(t, "xhttp; fwd=stale; fwd-status=304", status)
+		require.EqualValues(t, 2, count.Load())
+
+		// Conditional requests from the caller are not intercepted.
+		_, _, code = do("GET", "/etag", map[string]string{"If-None-Match": `"v1"`})
+		require.Equal(t, 304, code)
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		count.Store(0)
+
+		body, _, _ := do("GET", "/vary", map[string]string{"Accept-Language": "en"})
+		require.Equal(t, "hello in en", body)
+
+		body, status, _ := do("GET", "/vary", map[string]string{"Accept-Language": "en"})
+		require.Equal(t, "hello in en", body)
+		require.Equal(t, "xhttp; hit", status)
+
+		body, status, _ = do("GET", "/vary", map[string]string{"Accept-Language": "fr"})
+		require.Equal(t, "hello in fr", body)
+		require.Equal(t, "xhttp; fwd=miss", status)
+
+		require.EqualValues(t, 2, count.Load())
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		count.Store(0)
+
+		body, _, _ := do("GET", "/no-store", nil)
+		require.Equal(t, "no-store 1", body)
+
+		body, _, _ = do("GET", "/no-store", nil)
+		require.Equal(t, "no-store 2", body)
+	})
+}
+
+func TestCacheHandler(t *testing.T) {
+	var count atomic.Int32
+
+	handler := xhttp.CacheHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := strconv.Itoa(int(count.Add(1)))
+			switch r.URL.Path {
+			case "/private":
+				w.Header().Set("Cache-Control", "private, max-age=60")
+			case "/login":
+				w.Header().Set("Cache-Control", "s-maxage=60")
+				w.Header().Add("Set-Cookie", "session="+n)
+			default:
+				w.Header().Set("Cache-Control", "s-maxage=60")
+				w.Header().Set("ETag", `"`+n+`"`)
+			}
+			io.WriteString(w, "response "+n)
+		}),
+		xhttp.CacheOptions{},
+	)
+
+	serve := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, "response 1", serve("/public", nil).Body.String())
+
+	hit := serve("/public", nil)
+	require.Equal(t, "response 1", hit.Body.String())
+	require.Equal(t, "xhttp; hit", hit.Header().Get("Cache-Status"))
+
+	notModified := serve("/public", map[string]string{"If-None-Match": `W/"0", "1"`})
+	require.Equal(t, 304, notModified.Code)
+	require.Empty(t, notModified.Body.String())
+
+	require.Equal(t, "response 2", serve("/private", nil).Body.String())
+	require.Equal(t, "response 3", serve("/private", nil).Body.String())
+
+	// Responses setting cookies are not replayed to other clients.
+	login := serve("/login", nil)
+	require.Equal(t, "response 4", login.Body.String())
+	require.Equal(t, "session=4", login.Header().Get("Set-Cookie"))
+
+	login = serve("/login", nil)
+	require.Equal(t, "response 5", login.Body.String())
+	require.Equal(t, "session=5", login.Header().Get("Set-Cookie"))
+
+	require.EqualValues(t, 5, count.Load())
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := xhttp.NewMemoryCache(2)
+
+	cache.Set("a", &xhttp.CacheEntry{Body: []byte("a")})
+	cache.Set("b", &xhttp.CacheEntry{Body: []byte("b")})
+
+	// Touch a such that b is the least recently used.
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+
+	cache.Set("c", &xhttp.CacheEntry{Body: []byte("c")})
+
+	_, ok = cache.Get("b")
+	require.False(t, ok)
+
+	for _, key := range []string{"a", "c"} {
+		entry, ok := cache.Get(key)
+		require.True(t, ok)
+		require.Equal(t, key, strings.ToLower(string(entry.Body)))
+	}
+
+	cache.Delete("a")
+	require.Equal(t, 1, cache.Len())
+}
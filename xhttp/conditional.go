@@ -0,0 +1,430 @@
+package xhttp
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ConditionalOptions struct {
+	// MaxRanges is the maximum number of ranges served for a single request, after overlapping ranges have been
+	// merged. Requests for more ranges are served the full response. Defaults to 16.
+	MaxRanges int
+}
+
+// ConditionalHandler evaluates the conditional and range headers of requests against the validators declared by
+// handler. Handlers declare the ETag and Last-Modified headers, and the Content-Length for range requests, before
+// writing the response header. ConditionalHandler then handles If-Match, If-Unmodified-Since, If-None-Match,
+// If-Modified-Since, If-Range and Range headers for successful responses, as http.ServeContent does but without
+// requiring an io.ReadSeeker.
+//
+// The handler always writes its full response: the parts that are not requested are discarded. When a 304 Not
+// Modified or 412 Precondition Failed is served instead, writes fail with http.ErrBodyNotAllowed so that the handler
+// can stop early.
+func ConditionalHandler(handler http.Handler, opts ConditionalOptions) http.Handler {
+	if opts.MaxRanges <= 0 {
+		opts.MaxRanges = 16
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &conditionalWriter{ResponseWriter: w, request: r, opts: opts}
+		defer cw.close()
+
+		handler.ServeHTTP(cw, r)
+	})
+}
+
+// Conditional is the middleware form of ConditionalHandler.
+func Conditional(opts ConditionalOptions) Middleware {
+	return Named("xhttp.Conditional", func(handler http.Handler) http.Handler {
+		return ConditionalHandler(handler, opts)
+	})
+}
+
+// FileHandler serves the files of fsys, which need not implement io.Seeker. It is meant for use with xfs.FS and its
+// mocks, where http.FileServerFS cannot serve files. The Last-Modified and Content-Length headers are derived from the
+// file info, such that conditional and range requests are handled by a ConditionalHandler. Directories are not listed.
+func FileHandler(fsys fs.FS) http.Handler {
+	return ConditionalHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "."
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		file, err := fsys.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if !info.ModTime().IsZero() {
+			w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method != http.MethodHead {
+			io.Copy(w, file)
+		}
+	}), ConditionalOptions{})
+}
+
+type byteRange struct {
+	start, end int64
+}
+
+func (rng byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end-1, size)
+}
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	opts    ConditionalOptions
+
+	wroteHeader bool
+	discard     bool
+
+	ranges      []byteRange
+	size        int64
+	offset      int64
+	contentType string
+	multipart   *multipart.Writer
+	part        io.Writer
+	parts       int
+}
+
+func (w *conditionalWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.wroteHeader = true
+
+	if status == http.StatusOK {
+		status = w.evaluate()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *conditionalWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		if _, ok := w.Header()["Content-Type"]; !ok && len(data) > 0 {
+			// Sniff the content type from the start of the content rather than from the start of the first range.
+			w.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discard {
+		return 0, http.ErrBodyNotAllowed
+	}
+	if w.ranges == nil {
+		return w.ResponseWriter.Write(data)
+	}
+
+	start := w.offset
+	w.offset += int64(len(data))
+
+	for i, rng := range w.ranges {
+		lo, hi := max(rng.start, start), min(rng.end, w.offset)
+		if lo >= hi {
+			continue
+		}
+		if err := w.writeRange(i, data[lo-start:hi-start]); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (w *conditionalWriter) writeRange(index int, data []byte) error {
+	if w.multipart == nil {
+		_, err := w.ResponseWriter.Write(data)
+		return err
+	}
+
+	for w.parts <= index {
+		header := textproto.MIMEHeader{"Content-Range": {w.ranges[w.parts].contentRange(w.size)}}
+		if w.contentType != "" {
+			header.Set("Content-Type", w.contentType)
+		}
+		part, err := w.multipart.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		w.part = part
+		w.parts++
+	}
+
+	_, err := w.part.Write(data)
+	return err
+}
+
+func (w *conditionalWriter) close() {
+	if w.multipart != nil && w.parts == len(w.ranges) {
+		w.multipart.Close()
+	}
+}
+
+// Flush satisfies the http.Flusher interface.
+func (w *conditionalWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap satisfies the implicit http.rwUnwrapper interface.
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// evaluate applies the preconditions of the request to a 200 response in the order defined by RFC 9110 section 13.2.2,
+// and returns the status to be served instead.
+func (w *conditionalWriter) evaluate() int {
+	var (
+		r            = w.request
+		header       = w.Header()
+		etag         = header.Get("ETag")
+		lastModified = parseHTTPTime(header.Get("Last-Modified"))
+		safe         = r.Method == http.MethodGet || r.Method == http.MethodHead
+	)
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !strongMatch(ifMatch, etag) {
+			return w.precondition(http.StatusPreconditionFailed)
+		}
+	} else if since := parseHTTPTime(r.Header.Get("If-Unmodified-Since")); !since.IsZero() && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return w.precondition(http.StatusPreconditionFailed)
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if weakMatch(ifNoneMatch, etag) {
+			if safe {
+				return w.precondition(http.StatusNotModified)
+			}
+			return w.precondition(http.StatusPreconditionFailed)
+		}
+	} else if since := parseHTTPTime(r.Header.Get("If-Modified-Since")); safe && !since.IsZero() && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			return w.precondition(http.StatusNotModified)
+		}
+	}
+
+	return w.byteRanges(etag, lastModified)
+}
+
+func (w *conditionalWriter) precondition(status int) int {
+	w.discard = true
+	for _, key := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Range"} {
+		w.Header().Del(key)
+	}
+	return status
+}
+
+func (w *conditionalWriter) byteRanges(etag string, lastModified time.Time) int {
+	r, header := w.request, w.Header()
+
+	size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return http.StatusOK
+	}
+
+	header.Set("Accept-Ranges", "bytes")
+
+	if r.Method != http.MethodGet || r.Header.Get("Range") == "" || header.Get("Content-Encoding") != "" {
+		return http.StatusOK
+	}
+
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" {
+		if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+			if !strongMatch(ifRange, etag) {
+				return http.StatusOK
+			}
+		} else if since := parseHTTPTime(ifRange); since.IsZero() || !since.Equal(lastModified) {
+			return http.StatusOK
+		}
+	}
+
+	ranges, ok := parseRange(r.Header.Get("Range"), size)
+	if !ok || len(ranges) > w.opts.MaxRanges {
+		return http.StatusOK
+	}
+	if len(ranges) == 0 {
+		w.discard = true
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		return http.StatusRequestedRangeNotSatisfiable
+	}
+
+	w.ranges = ranges
+	w.size = size
+
+	if len(ranges) == 1 {
+		header.Set("Content-Range", ranges[0].contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ranges[0].end-ranges[0].start, 10))
+		return http.StatusPartialContent
+	}
+
+	w.contentType = header.Get("Content-Type")
+	w.multipart = multipart.NewWriter(w.ResponseWriter)
+
+	header.Set("Content-Type", "multipart/byteranges; boundary="+w.multipart.Boundary())
+	header.Set("Content-Length", strconv.FormatInt(w.multipartLength(), 10))
+
+	return http.StatusPartialContent
+}
+
+// multipartLength computes the length of the multipart/byteranges body by writing its framing to a counter.
+func (w *conditionalWriter) multipartLength() int64 {
+	var counter countingWriter
+
+	mw := multipart.NewWriter(&counter)
+	mw.SetBoundary(w.multipart.Boundary())
+
+	for _, rng := range w.ranges {
+		header := textproto.MIMEHeader{"Content-Range": {rng.contentRange(w.size)}}
+		if w.contentType != "" {
+			header.Set("Content-Type", w.contentType)
+		}
+		mw.CreatePart(header)
+		counter += countingWriter(rng.end - rng.start)
+	}
+	mw.Close()
+
+	return int64(counter)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	*w += countingWriter(len(data))
+	return len(data), nil
+}
+
+// parseRange parses a Range header against a representation of the given size. Satisfiable ranges are returned sorted,
+// with overlapping and adjacent ranges merged. It reports false if the header is invalid, in which case it must be
+// ignored, and returns no ranges if none are satisfiable.
+func parseRange(value string, size int64) (ranges []byteRange, ok bool) {
+	specs, ok := strings.CutPrefix(value, "bytes=")
+	if !ok {
+		return nil, false
+	}
+
+	ranges = []byteRange{}
+
+	for spec := range strings.SplitSeq(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, false
+		}
+
+		if first == "" {
+			suffix, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, false
+			}
+			if suffix > 0 && size > 0 {
+				ranges = append(ranges, byteRange{start: max(size-suffix, 0), end: size})
+			}
+			continue
+		}
+
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, false
+		}
+
+		end := size
+		if last != "" {
+			lastByte, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || lastByte < start {
+				return nil, false
+			}
+			end = min(lastByte+1, size)
+		}
+
+		if start < size {
+			ranges = append(ranges, byteRange{start: start, end: end})
+		}
+	}
+
+	slices.SortFunc(ranges, func(a, b byteRange) int { return cmp.Compare(a.start, b.start) })
+
+	merged := ranges[:0]
+	for _, rng := range ranges {
+		if n := len(merged); n > 0 && rng.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, rng.end)
+			continue
+		}
+		merged = append(merged, rng)
+	}
+
+	return merged, true
+}
+
+func parseHTTPTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// strongMatch reports whether etag matches the list of entity tags using the strong comparison: weak tags never match.
+func strongMatch(list, etag string) bool {
+	for candidate := range strings.SplitSeq(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || (candidate == etag && etag != "" && !strings.HasPrefix(etag, "W/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// weakMatch reports whether etag matches the list of entity tags using the weak comparison.
+func weakMatch(list, etag string) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	return etag != "" && slices.Contains(splitETags(list), strings.TrimPrefix(etag, "W/"))
+}
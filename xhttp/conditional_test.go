@@ -0,0 +1,295 @@
+package xhttp_test
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalHandler(t *testing.T) {
+	const content = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	var (
+		lastModified = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		writeErr     error
+	)
+
+	handler := xhttp.ConditionalHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(200)
+
+		// Write in small chunks to exercise ranges spanning writes.
+		for i := 0; i < len(content); i += 5 {
+			if _, writeErr = io.WriteString(w, content[i:min(i+5, len(content))]); writeErr != nil {
+				return
+			}
+		}
+	}), xhttp.ConditionalOptions{MaxRanges: 3})
+
+	type Response struct {
+		Status       int
+		Body         string
+		ContentRange string
+	}
+
+	cases := []struct {
+		Name     string
+		Method   string
+		Headers  map[string]string
+		Response Response
+		WriteErr error
+	}{
+		{
+			Name:     "unconditional",
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "if-none-match hit",
+			Headers:  map[string]string{"If-None-Match": `"v0", W/"v1"`},
+			Response: Response{Status: 304},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "if-none-match miss",
+			Headers:  map[string]string{"If-None-Match": `"v0"`},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "if-none-match on unsafe method",
+			Method:   "PUT",
+			Headers:  map[string]string{"If-None-Match": "*"},
+			Response: Response{Status: 412},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "if-modified-since not modified",
+			Headers:  map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			Response: Response{Status: 304},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "if-modified-since modified",
+			Headers:  map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name: "if-none-match takes precedence over if-modified-since",
+			Headers: map[string]string{
+				"If-None-Match":     `"v0"`,
+				"If-Modified-Since": lastModified.Format(http.TimeFormat),
+			},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "if-match failed",
+			Method:   "PUT",
+			Headers:  map[string]string{"If-Match": `"v0"`},
+			Response: Response{Status: 412},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "if-unmodified-since failed",
+			Headers:  map[string]string{"If-Unmodified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			Response: Response{Status: 412},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "single range",
+			Headers:  map[string]string{"Range": "bytes=3-12"},
+			Response: Response{Status: 206, Body: "3456789abc", ContentRange: "bytes 3-12/36"},
+		},
+		{
+			Name:     "suffix range",
+			Headers:  map[string]string{"Range": "bytes=-4"},
+			Response: Response{Status: 206, Body: "wxyz", ContentRange: "bytes 32-35/36"},
+		},
+		{
+			Name:     "open range",
+			Headers:  map[string]string{"Range": "bytes=30-"},
+			Response: Response{Status: 206, Body: "uvwxyz", ContentRange: "bytes 30-35/36"},
+		},
+		{
+			Name:     "overlapping ranges are merged",
+			Headers:  map[string]string{"Range": "bytes=5-9,0-6"},
+			Response: Response{Status: 206, Body: "0123456789", ContentRange: "bytes 0-9/36"},
+		},
+		{
+			Name:     "unsatisfiable range",
+			Headers:  map[string]string{"Range": "bytes=100-200"},
+			Response: Response{Status: 416, ContentRange: "bytes */36"},
+			WriteErr: http.ErrBodyNotAllowed,
+		},
+		{
+			Name:     "invalid range is ignored",
+			Headers:  map[string]string{"Range": "bytes=9-3"},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "too many ranges are ignored",
+			Headers:  map[string]string{"Range": "bytes=0-0,2-2,4-4,6-6"},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "if-range matching etag",
+			Headers:  map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`},
+			Response: Response{Status: 206, Body: "01", ContentRange: "bytes 0-1/36"},
+		},
+		{
+			Name:     "if-range stale etag",
+			Headers:  map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`},
+			Response: Response{Status: 200, Body: content},
+		},
+		{
+			Name:     "if-range matching date",
+			Headers:  map[string]string{"Range": "bytes=0-1", "If-Range": lastModified.Format(http.TimeFormat)},
+			Response: Response{Status: 206, Body: "01", ContentRange: "bytes 0-1/36"},
+		},
+		{
+			Name:     "if-range stale date",
+			Headers:  map[string]string{"Range": "bytes=0-1", "If-Range": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			Response: Response{Status: 200, Body: content},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			method := tc.Method
+			if method == "" {
+				method = "GET"
+			}
+
+			r := httptest.NewRequest(method, "/", nil)
+			for key, value := range tc.Headers {
+				r.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(
+				t,
+				tc.Response,
+				Response{Status: w.Code, Body: w.Body.String(), ContentRange: w.Header().Get("Content-Range")},
+			)
+			require.ErrorIs(t, writeErr, tc.WriteErr)
+
+			if tc.Response.Status == 206 {
+				require.Equal(t, strconv.Itoa(len(tc.Response.Body)), w.Header().Get("Content-Length"))
+			}
+		})
+	}
+
+	t.Run("multipart byteranges", func(t *testing.T) {
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", "bytes=0-2,10-12,-2")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, 206, resp.StatusCode)
+
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		type Part struct {
+			ContentType  string
+			ContentRange string
+			Body         string
+		}
+
+		var parts []Part
+
+		reader := multipart.NewReader(resp.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(part)
+			require.NoError(t, err)
+
+			parts = append(parts, Part{
+				ContentType:  part.Header.Get("Content-Type"),
+				ContentRange: part.Header.Get("Content-Range"),
+				Body:         string(body),
+			})
+		}
+
+		require.Equal(
+			t,
+			[]Part{
+				{ContentType: "text/plain", ContentRange: "bytes 0-2/36", Body: "012"},
+				{ContentType: "text/plain", ContentRange: "bytes 10-12/36", Body: "abc"},
+				{ContentType: "text/plain", ContentRange: "bytes 34-35/36", Body: "yz"},
+			},
+			parts,
+		)
+	})
+}
+
+func TestFileHandler(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	handler := xhttp.FileHandler(fstest.MapFS{
+		"dir/hello.txt": {Data: []byte("hello world"), ModTime: modTime},
+	})
+
+	cases := []struct {
+		Name    string
+		Path    string
+		Headers map[string]string
+		Status  int
+		Body    string
+	}{
+		{Name: "file", Path: "/dir/hello.txt", Status: 200, Body: "hello world"},
+		{Name: "range", Path: "/dir/hello.txt", Headers: map[string]string{"Range": "bytes=6-"}, Status: 206, Body: "world"},
+		{
+			Name:    "not modified",
+			Path:    "/dir/hello.txt",
+			Headers: map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)},
+			Status:  304,
+		},
+		{Name: "directory", Path: "/dir", Status: 404, Body: "404 page not found\n"},
+		{Name: "missing", Path: "/missing.txt", Status: 404, Body: "404 page not found\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.Path, nil)
+			for key, value := range tc.Headers {
+				r.Header.Set(key, value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, tc.Status, w.Code)
+			require.Equal(t, tc.Body, w.Body.String())
+			if tc.Status == 200 {
+				require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+				require.Equal(t, modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
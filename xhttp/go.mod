@@ -2,7 +2,13 @@ module github.com/davidmdm/x/xhttp
 
 go 1.26
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/davidmdm/x/xerr v0.0.5
+	github.com/davidmdm/x/xio v0.0.1
+	github.com/davidmdm/x/xruntime v0.0.1
+	github.com/davidmdm/x/xsync v0.0.1
+	github.com/stretchr/testify v1.9.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
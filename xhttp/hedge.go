@@ -0,0 +1,207 @@
+package xhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+type HedgeOptions struct {
+	// Transport is the underlying round tripper. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Delay is the duration to wait for response headers before sending a hedged request. Defaults to 100ms.
+	// If Percentile is set, Delay is only used until enough latencies have been observed.
+	Delay time.Duration
+	// Percentile, if between 0 and 1, derives the delay from the latencies observed for previous requests.
+	// For example 0.95 sends a hedged request once a request is slower than 95% of recent requests.
+	Percentile float64
+	// MinSamples is the number of observed latencies required before the Percentile is used. Defaults to 20.
+	MinSamples int
+	// MaxHedges is the maximum number of hedged requests sent in addition to the original. Defaults to 1.
+	MaxHedges int
+	// Clock provides the timers used to schedule hedged requests. Defaults to the system clock.
+	Clock Clock
+}
+
+// Hedger is an http.RoundTripper that sends duplicate ("hedged") requests when the original has not received its
+// response headers within a delay. The first response wins and the other requests are canceled via their context.
+// Only requests that are safe to replay are hedged: requests with an idempotent method (GET, HEAD, OPTIONS, TRACE)
+// or an Idempotency-Key header, whose body is empty or can be replayed with GetBody. Other requests are sent as is.
+type Hedger struct {
+	opts HedgeOptions
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+var _ http.RoundTripper = (*Hedger)(nil)
+
+const hedgeSamples = 128
+
+func NewHedger(opts HedgeOptions) *Hedger {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = 100 * time.Millisecond
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = 20
+	}
+	if opts.MaxHedges <= 0 {
+		opts.MaxHedges = 1
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	return &Hedger{opts: opts}
+}
+
+type hedgeResult struct {
+	attempt int
+	start   time.Time
+	resp    *http.Response
+	err     error
+}
+
+func (h *Hedger) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !replayable(r) {
+		return h.opts.Transport.RoundTrip(r)
+	}
+
+	var (
+		delay   = h.delay()
+		results = make(chan hedgeResult, h.opts.MaxHedges+1)
+		hedge   = make(chan struct{}, 1)
+		cancels []context.CancelFunc
+	)
+
+	send := func(body io.ReadCloser) {
+		ctx, cancel := context.WithCancel(r.Context())
+
+		req := r.Clone(ctx)
+		req.Body = body
+
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			resp, err := h.opts.Transport.RoundTrip(req)
+			results <- hedgeResult{attempt: attempt, start: start, resp: resp, err: err}
+		}()
+	}
+
+	timer := h.opts.Clock.AfterFunc(delay, func() { hedge <- struct{}{} })
+	defer timer.Stop()
+
+	send(r.Body)
+	inflight := 1
+
+	for {
+		select {
+		case <-hedge:
+			body := r.Body
+			if r.GetBody != nil {
+				var err error
+				if body, err = r.GetBody(); err != nil {
+					continue
+				}
+			}
+			send(body)
+			inflight++
+			if len(cancels) <= h.opts.MaxHedges {
+				timer.Reset(delay)
+			}
+
+		case result := <-results:
+			inflight--
+
+			if result.err != nil {
+				cancels[result.attempt]()
+				if inflight == 0 {
+					return nil, result.err
+				}
+				continue
+			}
+
+			h.observe(time.Since(result.start))
+
+			for i, cancel := range cancels {
+				if i != result.attempt {
+					cancel()
+				}
+			}
+			go discard(results, inflight)
+
+			result.resp.Body = cancelOnClose{ReadCloser: result.resp.Body, cancel: cancels[result.attempt]}
+			return result.resp, nil
+		}
+	}
+}
+
+// discard closes the responses of the n requests that lost the race.
+func discard(results <-chan hedgeResult, n int) {
+	for range n {
+		if result := <-results; result.resp != nil {
+			result.resp.Body.Close()
+		}
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body cancelOnClose) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
+
+func (h *Hedger) delay() time.Duration {
+	if h.opts.Percentile <= 0 || h.opts.Percentile >= 1 {
+		return h.opts.Delay
+	}
+
+	h.mu.Lock()
+	latencies := slices.Clone(h.latencies)
+	h.mu.Unlock()
+
+	if len(latencies) < h.opts.MinSamples {
+		return h.opts.Delay
+	}
+
+	slices.Sort(latencies)
+	return latencies[int(h.opts.Percentile*float64(len(latencies)-1))]
+}
+
+func (h *Hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < hedgeSamples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeSamples
+}
+
+// replayable mirrors the rules used by net/http to decide whether a request can be safely retried.
+func replayable(r *http.Request) bool {
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		return false
+	}
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	_, hasKey := r.Header["Idempotency-Key"]
+	_, hasXKey := r.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
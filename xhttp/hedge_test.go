@@ -0,0 +1,132 @@
+package xhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedger(t *testing.T) {
+	var (
+		count    atomic.Int32
+		canceled = make(chan struct{}, 1)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		n := count.Add(1)
+		if n == 1 {
+			select {
+			case <-r.Context().Done():
+				canceled <- struct{}{}
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		io.WriteString(w, "attempt "+strconv.Itoa(int(n))+": "+string(body))
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: xhttp.NewHedger(xhttp.HedgeOptions{Delay: 20 * time.Millisecond}),
+	}
+
+	t.Run("hedged", func(t *testing.T) {
+		count.Store(0)
+
+		req, err := http.NewRequest("PUT", server.URL, strings.NewReader("payload"))
+		require.NoError(t, err)
+		req.Header.Set("Idempotency-Key", "1234")
+
+		start := time.Now()
+
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, "attempt 2: payload", string(body))
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("expected slow attempt to be canceled")
+		}
+	})
+
+	t.Run("not replayable", func(t *testing.T) {
+		count.Store(0)
+
+		start := time.Now()
+
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		require.Equal(t, "attempt 1: payload", string(body))
+		require.GreaterOrEqual(t, time.Since(start), time.Second)
+		require.EqualValues(t, 1, count.Load())
+	})
+}
+
+func TestHedgerPercentile(t *testing.T) {
+	var slow atomic.Bool
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.CompareAndSwap(true, false) {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: xhttp.NewHedger(xhttp.HedgeOptions{
+			Delay:      500 * time.Millisecond,
+			Percentile: 0.9,
+			MinSamples: 5,
+		}),
+	}
+
+	get := func() {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "ok", string(body))
+	}
+
+	// Warm up the observed latencies with fast responses.
+	for range 10 {
+		get()
+	}
+
+	slow.Store(true)
+
+	start := time.Now()
+	get()
+
+	// The observed latencies are far below the fallback Delay, so the hedged request must be sent much sooner.
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+}
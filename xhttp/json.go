@@ -0,0 +1,196 @@
+package xhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/davidmdm/x/xerr"
+)
+
+// StatusError is an error that knows the HTTP status code it should be served with. The messages of errors
+// implementing StatusError are considered safe to expose to clients.
+type StatusError interface {
+	error
+	StatusCode() int
+}
+
+// WithStatus annotates err with an HTTP status code. It returns nil if err is nil.
+func WithStatus(err error, status int) error {
+	if err == nil {
+		return nil
+	}
+	return statusError{err: err, status: status}
+}
+
+type statusError struct {
+	err    error
+	status int
+}
+
+func (err statusError) Error() string   { return err.err.Error() }
+func (err statusError) Unwrap() error   { return err.err }
+func (err statusError) StatusCode() int { return err.status }
+
+// Problem is a problem details object as described by RFC 9457.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Errors is an extension member listing the individual errors of an xerr.MultiErr.
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+type ProblemError struct {
+	Detail string `json:"detail"`
+	Status int    `json:"status,omitempty"`
+}
+
+// ProblemFromError builds the problem details for err. The status is taken from the first StatusError found in
+// the error chain, and defaults to 500 Internal Server Error. Only the messages of StatusErrors are considered safe to
+// expose: the detail is the message of the StatusError, without the context added by the errors wrapping it.
+//
+// If the error is an xerr.MultiErr, each of its errors is listed. The messages of its errors are exposed when the
+// MultiErr is itself wrapped by the StatusError. Otherwise only the errors that are StatusErrors are exposed, and the
+// others are listed by the text of their status.
+func ProblemFromError(err error) Problem {
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) {
+		return Problem{
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	problem := Problem{
+		Title:  http.StatusText(statusErr.StatusCode()),
+		Status: statusErr.StatusCode(),
+		Detail: statusErr.Error(),
+	}
+
+	var multi xerr.MultiErr
+
+	safe := errors.As(statusErr, &multi)
+	if !safe && !errors.As(err, &multi) {
+		return problem
+	}
+
+	if safe {
+		problem.Detail = multi.Msg
+	}
+
+	for _, e := range multi.Errors {
+		item := ProblemError{Detail: http.StatusText(http.StatusInternalServerError)}
+		if safe {
+			item.Detail = e.Error()
+		}
+		if se := StatusError(nil); errors.As(e, &se) {
+			item.Status = se.StatusCode()
+			if !safe {
+				item.Detail = se.Error()
+			}
+		}
+		problem.Errors = append(problem.Errors, item)
+	}
+
+	return problem
+}
+
+// WriteProblem writes the problem details for err to w with the application/problem+json content type.
+func WriteProblem(w http.ResponseWriter, err error) {
+	problem := ProblemFromError(err)
+
+	data, _ := json.Marshal(problem)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(problem.Status)
+	w.Write(data)
+}
+
+type JSONOptions struct {
+	// MaxBodySize is the maximum size of the request body. Defaults to 1MB.
+	MaxBodySize int64
+	// DisallowUnknownFields causes requests with fields that do not exist in the request type to be rejected.
+	DisallowUnknownFields bool
+	// Status is the status code of successful responses. Defaults to 200 OK.
+	Status int
+}
+
+// JSON adapts fn into an http.Handler using the default JSONOptions. See JSONHandler.
+func JSON[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) http.Handler {
+	return JSONHandler(fn, JSONOptions{})
+}
+
+// JSONHandler adapts fn into an http.Handler. The request body is decoded as JSON into Req, unless it is empty in
+// which case fn receives the zero value of Req. The value returned by fn is encoded as the JSON response. Errors,
+// including decoding errors, are served as problem details (see ProblemFromError).
+func JSONHandler[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error), opts JSONOptions) http.Handler {
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+	if opts.Status == 0 {
+		opts.Status = http.StatusOK
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := decodeJSON[Req](w, r, opts)
+		if err != nil {
+			WriteProblem(w, err)
+			return
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			WriteProblem(w, err)
+			return
+		}
+
+		data, err := json.Marshal(resp)
+		if err != nil {
+			WriteProblem(w, fmt.Errorf("failed to encode response: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(opts.Status)
+		w.Write(data)
+	})
+}
+
+func decodeJSON[T any](w http.ResponseWriter, r *http.Request, opts JSONOptions) (value T, err error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, opts.MaxBodySize))
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err = decoder.Decode(&value); err != nil {
+		// Bodies of unknown length, such as chunked ones, can only be found to be empty by reading them.
+		if err == io.EOF {
+			return value, nil
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr), errors.Is(err, ErrBodyTooLarge):
+			return value, WithStatus(fmt.Errorf("failed to decode request: %w", err), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, ErrBodyReadStalled):
+			return value, WithStatus(fmt.Errorf("failed to decode request: %w", err), http.StatusRequestTimeout)
+		default:
+			return value, WithStatus(fmt.Errorf("failed to decode request: %w", err), http.StatusBadRequest)
+		}
+	}
+
+	return
+}
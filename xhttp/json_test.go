@@ -0,0 +1,217 @@
+package xhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidmdm/x/xerr"
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	type Req struct {
+		Name string `json:"name"`
+	}
+	type Resp struct {
+		Greeting string `json:"greeting"`
+	}
+
+	greet := func(ctx context.Context, req Req) (Resp, error) {
+		switch req.Name {
+		case "":
+			return Resp{}, xhttp.WithStatus(
+				xerr.MultiErrFrom("invalid request", errors.New("name is required")),
+				http.StatusUnprocessableEntity,
+			)
+		case "teapot":
+			return Resp{}, xhttp.WithStatus(errors.New("I'm a teapot"), http.StatusTeapot)
+		case "secret":
+			return Resp{}, errors.New("database password is hunter2")
+		case "mixed":
+			return Resp{}, xerr.MultiErrFrom(
+				"invalid request",
+				xhttp.WithStatus(errors.New("name is reserved"), http.StatusBadRequest),
+				errors.New("database password is hunter2"),
+			)
+		case "wrapped":
+			return Resp{}, fmt.Errorf("lookup user token=abc123: %w", xhttp.WithStatus(errors.New("not found"), http.StatusNotFound))
+		}
+		return Resp{Greeting: "hello " + req.Name}, nil
+	}
+
+	cases := []struct {
+		Name            string
+		Opts            xhttp.JSONOptions
+		Body            string
+		ExpectedStatus  int
+		ExpectedType    string
+		ExpectedBody    string
+		ExpectedProblem *xhttp.Problem
+	}{
+		{
+			Name:           "happy",
+			Body:           `{"name":"bob"}`,
+			ExpectedStatus: 200,
+			ExpectedType:   "application/json",
+			ExpectedBody:   `{"greeting":"hello bob"}`,
+		},
+		{
+			Name:           "custom success status",
+			Opts:           xhttp.JSONOptions{Status: http.StatusCreated},
+			Body:           `{"name":"bob"}`,
+			ExpectedStatus: 201,
+			ExpectedType:   "application/json",
+			ExpectedBody:   `{"greeting":"hello bob"}`,
+		},
+		{
+			Name:           "status error",
+			Body:           `{"name":"teapot"}`,
+			ExpectedStatus: 418,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "I'm a teapot",
+				Status: 418,
+				Detail: "I'm a teapot",
+			},
+		},
+		{
+			Name:           "multi error",
+			Body:           `{}`,
+			ExpectedStatus: 422,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Unprocessable Entity",
+				Status: 422,
+				Detail: "invalid request",
+				Errors: []xhttp.ProblemError{{Detail: "name is required"}},
+			},
+		},
+		{
+			Name:           "mixed multi error",
+			Body:           `{"name":"mixed"}`,
+			ExpectedStatus: 400,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "name is reserved",
+				Errors: []xhttp.ProblemError{
+					{Detail: "name is reserved", Status: 400},
+					{Detail: "Internal Server Error"},
+				},
+			},
+		},
+		{
+			Name:           "wrapped status error",
+			Body:           `{"name":"wrapped"}`,
+			ExpectedStatus: 404,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "not found",
+			},
+		},
+		{
+			Name:           "internal errors are not exposed",
+			Body:           `{"name":"secret"}`,
+			ExpectedStatus: 500,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Internal Server Error",
+				Status: 500,
+			},
+		},
+		{
+			Name:           "malformed body",
+			Body:           `{"name":`,
+			ExpectedStatus: 400,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "failed to decode request: unexpected EOF",
+			},
+		},
+		{
+			Name:           "unknown fields",
+			Opts:           xhttp.JSONOptions{DisallowUnknownFields: true},
+			Body:           `{"name":"bob","age":42}`,
+			ExpectedStatus: 400,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: `failed to decode request: json: unknown field "age"`,
+			},
+		},
+		{
+			Name:           "body too large",
+			Opts:           xhttp.JSONOptions{MaxBodySize: 8},
+			Body:           `{"name":"bob"}`,
+			ExpectedStatus: 413,
+			ExpectedType:   "application/problem+json",
+			ExpectedProblem: &xhttp.Problem{
+				Title:  "Request Entity Too Large",
+				Status: 413,
+				Detail: "failed to decode request: http: request body too large",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			handler := xhttp.JSONHandler(greet, tc.Opts)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(tc.Body)))
+
+			require.Equal(t, tc.ExpectedStatus, w.Code)
+			require.Equal(t, tc.ExpectedType, w.Header().Get("Content-Type"))
+
+			if tc.ExpectedProblem == nil {
+				require.Equal(t, tc.ExpectedBody, w.Body.String())
+				return
+			}
+
+			var problem xhttp.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			require.Equal(t, *tc.ExpectedProblem, problem)
+		})
+	}
+}
+
+func TestJSONEmptyBody(t *testing.T) {
+	handler := xhttp.JSON(func(ctx context.Context, req struct{}) ([]string, error) {
+		return []string{"a", "b"}, nil
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `["a","b"]`, w.Body.String())
+}
+
+func TestJSONEmptyChunkedBody(t *testing.T) {
+	handler := xhttp.JSON(func(ctx context.Context, req struct{ Name string }) (string, error) {
+		return "hello " + req.Name, nil
+	})
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(""))
+	r.ContentLength = -1
+	r.TransferEncoding = []string{"chunked"}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, `"hello "`, w.Body.String())
+}
@@ -0,0 +1,121 @@
+package xhttp
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Middleware wraps a handler with additional behavior.
+type Middleware func(http.Handler) http.Handler
+
+// ChainedHandler is the handler produced by a Chain. It records which middlewares wrap the endpoint.
+type ChainedHandler struct {
+	http.Handler
+	// Middlewares are the names of the middlewares wrapping the endpoint, from outermost to innermost.
+	Middlewares []string
+	// Endpoint is the handler wrapped by the middlewares.
+	Endpoint http.Handler
+}
+
+// Chain composes middlewares into a single middleware. Middlewares are applied in the order they are declared:
+// the first middleware is the outermost and sees the request first. The handler returned by the resulting
+// middleware is a *ChainedHandler. Chains may be nested, in which case the resulting ChainedHandler lists the
+// middlewares of every chain.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler http.Handler) http.Handler {
+		chained := ChainedHandler{
+			Middlewares: Middlewares(handler),
+			Endpoint:    handler,
+		}
+		if inner, ok := handler.(*ChainedHandler); ok {
+			chained.Endpoint = inner.Endpoint
+		}
+
+		for _, middleware := range slices.Backward(middlewares) {
+			next := handler
+			handler = middleware(handler)
+
+			var names []string
+			switch h := handler.(type) {
+			case *ChainedHandler:
+				if h != next {
+					names = append([]string{}, h.Middlewares[:len(h.Middlewares)-len(Middlewares(next))]...)
+				}
+			case *namedHandler:
+				if h != next {
+					names = []string{h.name}
+				}
+			}
+			if names == nil {
+				names = []string{funcName(middleware)}
+			}
+
+			chained.Middlewares = append(names, chained.Middlewares...)
+		}
+
+		chained.Handler = handler
+		return &chained
+	}
+}
+
+// Middlewares returns the names of the middlewares wrapping handler if it was produced by a Chain.
+// Combined with http.ServeMux.Handler it can be used to list which middlewares protect which route.
+func Middlewares(handler http.Handler) []string {
+	if chained, ok := handler.(*ChainedHandler); ok {
+		return chained.Middlewares
+	}
+	return nil
+}
+
+// Named gives a name to a middleware, which is reported by ChainedHandlers. Middlewares that are not named are
+// reported by the name of their function.
+func Named(name string, middleware Middleware) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return &namedHandler{name: name, Handler: middleware(handler)}
+	}
+}
+
+type namedHandler struct {
+	http.Handler
+	name string
+}
+
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	// Trim the anonymous function suffixes such as ".func1" of closures returned by middleware constructors.
+	for {
+		idx := strings.LastIndex(name, ".func")
+		if idx < 0 {
+			return name
+		}
+		if _, err := strconv.Atoi(name[idx+len(".func"):]); err != nil {
+			return name
+		}
+		name = name[:idx]
+	}
+}
+
+// Timeout is the middleware form of TimeoutHandler.
+func Timeout(opts TimeoutOptions) Middleware {
+	return Named("xhttp.Timeout", func(handler http.Handler) http.Handler {
+		return TimeoutHandler(handler, opts)
+	})
+}
+
+// Record is the middleware form of RecordingHandler.
+func Record(report func(*http.Request, Recording)) Middleware {
+	return Named("xhttp.Record", func(handler http.Handler) http.Handler {
+		return RecordingHandler(handler, report)
+	})
+}
+
+// Body is the middleware form of BodyHandler.
+func Body(opts BodyOptions) Middleware {
+	return Named("xhttp.Body", func(handler http.Handler) http.Handler {
+		return BodyHandler(handler, opts)
+	})
+}
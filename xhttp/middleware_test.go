@@ -0,0 +1,83 @@
+package xhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func tag(name string, calls *[]string) xhttp.Middleware {
+	return xhttp.Named(name, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+func anonymous(next http.Handler) http.Handler {
+	return next
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+
+	endpoint := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "endpoint")
+		io.WriteString(w, "ok")
+	})
+
+	handler := xhttp.Chain(
+		tag("a", &calls),
+		xhttp.Chain(tag("b", &calls), tag("c", &calls)),
+		anonymous,
+		xhttp.Timeout(xhttp.TimeoutOptions{Initial: time.Second}),
+	)(endpoint)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	require.Equal(t, "ok", w.Body.String())
+	require.Equal(t, []string{"a", "b", "c", "endpoint"}, calls)
+
+	require.Equal(
+		t,
+		[]string{"a", "b", "c", "github.com/davidmdm/x/xhttp_test.anonymous", "xhttp.Timeout"},
+		xhttp.Middlewares(handler),
+	)
+
+	chained, ok := handler.(*xhttp.ChainedHandler)
+	require.True(t, ok)
+	require.NotNil(t, chained.Endpoint)
+}
+
+func TestChainExtendsChainedHandler(t *testing.T) {
+	var calls []string
+
+	base := xhttp.Chain(tag("inner", &calls))(http.NotFoundHandler())
+	handler := xhttp.Chain(tag("outer", &calls))(base)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	require.Equal(t, []string{"outer", "inner"}, calls)
+	require.Equal(t, []string{"outer", "inner"}, xhttp.Middlewares(handler))
+}
+
+func TestMiddlewaresPerRoute(t *testing.T) {
+	var calls []string
+
+	mux := http.NewServeMux()
+	mux.Handle("/public", http.NotFoundHandler())
+	mux.Handle("/private", xhttp.Chain(tag("auth", &calls), xhttp.Body(xhttp.BodyOptions{MaxSize: 1024}))(http.NotFoundHandler()))
+
+	public, _ := mux.Handler(httptest.NewRequest("GET", "/public", nil))
+	private, _ := mux.Handler(httptest.NewRequest("GET", "/private", nil))
+
+	require.Empty(t, xhttp.Middlewares(public))
+	require.Equal(t, []string{"auth", "xhttp.Body"}, xhttp.Middlewares(private))
+}
@@ -0,0 +1,232 @@
+package xhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyUpstream is passed to the ErrorHandler of a ReverseProxy when none of its upstreams are healthy.
+var ErrNoHealthyUpstream = errors.New("no healthy upstream")
+
+type Balancing int
+
+const (
+	// RoundRobin sends requests to each healthy upstream in turn.
+	RoundRobin Balancing = iota
+	// LeastConnections sends requests to the healthy upstream with the fewest requests in flight.
+	LeastConnections
+)
+
+// Upstream is a server requests are proxied to.
+type Upstream struct {
+	// URL is the base URL of the upstream. The path of proxied requests is joined to its path.
+	URL *url.URL
+	// Timeout is applied to the proxied response via a TimeoutHandler. The Initial timeout bounds the time to the
+	// response headers of the upstream, and the Rolling timeout the time between writes of the response body.
+	Timeout TimeoutOptions
+}
+
+type HealthCheckOptions struct {
+	// Path is requested on each upstream to check its health. If empty, health checks are disabled and every
+	// upstream is considered healthy.
+	Path string
+	// Interval is the time between health checks. Defaults to 10 seconds.
+	Interval time.Duration
+	// Timeout bounds the duration of a health check. Defaults to 2 seconds.
+	Timeout time.Duration
+	// Healthy reports whether the result of a health check is healthy. Defaults to 2xx and 3xx responses.
+	Healthy func(*http.Response, error) bool
+}
+
+type ProxyOptions struct {
+	Upstreams []Upstream
+	// Balancing is the strategy used to pick an upstream. Defaults to RoundRobin.
+	Balancing Balancing
+	// HealthCheck configures active health checks of the upstreams.
+	HealthCheck HealthCheckOptions
+	// Transport is used to proxy requests and run health checks. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper
+	// Rewrite, if set, is called to modify proxied requests after their URL and X-Forwarded headers have been set.
+	Rewrite func(*httputil.ProxyRequest)
+	// ErrorHandler handles errors reaching the upstreams. Defaults to a 502 Bad Gateway response.
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+// UpstreamStatus is a snapshot of the state of an upstream of a ReverseProxy.
+type UpstreamStatus struct {
+	URL     *url.URL
+	Healthy bool
+	// Active is the number of requests in flight.
+	Active int
+}
+
+// ReverseProxy is an http.Handler proxying requests to a set of upstreams using an httputil.ReverseProxy.
+// Requests are balanced between the healthy upstreams, and each upstream's response is subject to its own timeouts.
+// Health checks run in the background until the ReverseProxy is closed.
+type ReverseProxy struct {
+	opts      ProxyOptions
+	upstreams []*upstream
+	next      atomic.Uint64
+
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+var _ http.Handler = (*ReverseProxy)(nil)
+
+type upstream struct {
+	Upstream
+	handler http.Handler
+	active  atomic.Int64
+	healthy atomic.Bool
+}
+
+func NewReverseProxy(opts ProxyOptions) *ReverseProxy {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = defaultProxyErrorHandler
+	}
+	if opts.HealthCheck.Interval <= 0 {
+		opts.HealthCheck.Interval = 10 * time.Second
+	}
+	if opts.HealthCheck.Timeout <= 0 {
+		opts.HealthCheck.Timeout = 2 * time.Second
+	}
+	if opts.HealthCheck.Healthy == nil {
+		opts.HealthCheck.Healthy = defaultHealthy
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+
+	proxy := &ReverseProxy{opts: opts, stop: stop}
+
+	for _, target := range opts.Upstreams {
+		u := &upstream{Upstream: target}
+		u.healthy.Store(true)
+		u.handler = TimeoutHandler(
+			&httputil.ReverseProxy{
+				Rewrite: func(r *httputil.ProxyRequest) {
+					r.SetURL(target.URL)
+					r.SetXForwarded()
+					if opts.Rewrite != nil {
+						opts.Rewrite(r)
+					}
+				},
+				Transport:    opts.Transport,
+				ErrorHandler: opts.ErrorHandler,
+			},
+			target.Timeout,
+		)
+		proxy.upstreams = append(proxy.upstreams, u)
+	}
+
+	if opts.HealthCheck.Path != "" {
+		for _, u := range proxy.upstreams {
+			proxy.wg.Go(func() { proxy.watch(ctx, u) })
+		}
+	}
+
+	return proxy
+}
+
+func defaultProxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+}
+
+func defaultHealthy(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+func (proxy *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u := proxy.pick()
+	if u == nil {
+		proxy.opts.ErrorHandler(w, r, ErrNoHealthyUpstream)
+		return
+	}
+
+	u.active.Add(1)
+	defer u.active.Add(-1)
+
+	u.handler.ServeHTTP(w, r)
+}
+
+// Status returns the state of each upstream, in the order they were configured.
+func (proxy *ReverseProxy) Status() []UpstreamStatus {
+	status := make([]UpstreamStatus, len(proxy.upstreams))
+	for i, u := range proxy.upstreams {
+		status[i] = UpstreamStatus{URL: u.URL, Healthy: u.healthy.Load(), Active: int(u.active.Load())}
+	}
+	return status
+}
+
+// Close stops the health checks.
+func (proxy *ReverseProxy) Close() error {
+	proxy.stop()
+	proxy.wg.Wait()
+	return nil
+}
+
+func (proxy *ReverseProxy) pick() (picked *upstream) {
+	n := uint64(len(proxy.upstreams))
+	if n == 0 {
+		return nil
+	}
+
+	start := proxy.next.Add(1) - 1
+	for i := range n {
+		u := proxy.upstreams[(start+i)%n]
+		if !u.healthy.Load() {
+			continue
+		}
+		if proxy.opts.Balancing == RoundRobin {
+			return u
+		}
+		if picked == nil || u.active.Load() < picked.active.Load() {
+			picked = u
+		}
+	}
+	return picked
+}
+
+func (proxy *ReverseProxy) watch(ctx context.Context, u *upstream) {
+	ticker := time.NewTicker(proxy.opts.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		u.healthy.Store(proxy.check(ctx, u))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (proxy *ReverseProxy) check(ctx context.Context, u *upstream) bool {
+	ctx, cancel := context.WithTimeout(ctx, proxy.opts.HealthCheck.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL.JoinPath(proxy.opts.HealthCheck.Path).String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := proxy.opts.Transport.RoundTrip(req)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// The proxy was closed during the check: keep the last known state.
+		return u.healthy.Load()
+	}
+	return proxy.opts.HealthCheck.Healthy(resp, err)
+}
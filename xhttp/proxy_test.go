@@ -0,0 +1,206 @@
+package xhttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	newUpstream := func(name string) (*httptest.Server, *url.URL) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+" "+r.URL.Path)
+		}))
+		t.Cleanup(server.Close)
+		target, err := url.Parse(server.URL)
+		require.NoError(t, err)
+		return server, target
+	}
+
+	get := func(t *testing.T, handler http.Handler, path string) (int, string) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		_, a := newUpstream("a")
+		_, b := newUpstream("b")
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams: []xhttp.Upstream{{URL: a}, {URL: b}},
+		})
+		defer proxy.Close()
+
+		var bodies []string
+		for range 4 {
+			_, body := get(t, proxy, "/path")
+			bodies = append(bodies, body)
+		}
+
+		require.Equal(t, []string{"a /path", "b /path", "a /path", "b /path"}, bodies)
+	})
+
+	t.Run("least connections", func(t *testing.T) {
+		release := make(chan struct{})
+
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			io.WriteString(w, "slow")
+		}))
+		defer slow.Close()
+		defer close(release)
+
+		slowURL, err := url.Parse(slow.URL)
+		require.NoError(t, err)
+
+		_, fast := newUpstream("fast")
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams: []xhttp.Upstream{{URL: slowURL}, {URL: fast}},
+			Balancing: xhttp.LeastConnections,
+		})
+		defer proxy.Close()
+
+		go get(t, proxy, "/")
+
+		require.Eventually(t, func() bool { return proxy.Status()[0].Active == 1 }, time.Second, time.Millisecond)
+
+		for range 3 {
+			_, body := get(t, proxy, "/")
+			require.Equal(t, "fast /", body)
+		}
+	})
+
+	t.Run("health checks", func(t *testing.T) {
+		var healthy atomic.Bool
+		healthy.Store(true)
+
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" && !healthy.Load() {
+				w.WriteHeader(503)
+				return
+			}
+			io.WriteString(w, "flaky")
+		}))
+		defer flaky.Close()
+
+		flakyURL, err := url.Parse(flaky.URL)
+		require.NoError(t, err)
+
+		_, stable := newUpstream("stable")
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams:   []xhttp.Upstream{{URL: flakyURL}, {URL: stable}},
+			HealthCheck: xhttp.HealthCheckOptions{Path: "/healthz", Interval: 5 * time.Millisecond},
+		})
+		defer proxy.Close()
+
+		healthy.Store(false)
+		require.Eventually(t, func() bool { return !proxy.Status()[0].Healthy }, time.Second, time.Millisecond)
+
+		for range 3 {
+			_, body := get(t, proxy, "/")
+			require.Equal(t, "stable /", body)
+		}
+
+		healthy.Store(true)
+		require.Eventually(t, func() bool { return proxy.Status()[0].Healthy }, time.Second, time.Millisecond)
+	})
+
+	t.Run("no healthy upstream", func(t *testing.T) {
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(500)
+		}))
+		defer down.Close()
+
+		downURL, err := url.Parse(down.URL)
+		require.NoError(t, err)
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams:   []xhttp.Upstream{{URL: downURL}},
+			HealthCheck: xhttp.HealthCheckOptions{Path: "/healthz", Interval: time.Hour},
+		})
+		defer proxy.Close()
+
+		require.Eventually(t, func() bool { return !proxy.Status()[0].Healthy }, time.Second, time.Millisecond)
+
+		status, _ := get(t, proxy, "/")
+		require.Equal(t, 502, status)
+	})
+
+	t.Run("per upstream timeouts", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			canceled bool
+		)
+
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			mu.Lock()
+			canceled = true
+			mu.Unlock()
+		}))
+		defer hanging.Close()
+
+		hangingURL, err := url.Parse(hanging.URL)
+		require.NoError(t, err)
+
+		_, fast := newUpstream("fast")
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams: []xhttp.Upstream{
+				{URL: hangingURL, Timeout: xhttp.TimeoutOptions{Initial: 20 * time.Millisecond}},
+				{URL: fast, Timeout: xhttp.TimeoutOptions{Initial: time.Minute}},
+			},
+		})
+		defer proxy.Close()
+
+		status, _ := get(t, proxy, "/")
+		require.Equal(t, 503, status)
+
+		status, body := get(t, proxy, "/")
+		require.Equal(t, 200, status)
+		require.Equal(t, "fast /", body)
+
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return canceled
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("multi-valued headers through timeouts", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Set-Cookie", "a=1")
+			w.Header().Add("Set-Cookie", "b=2")
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Accept-Encoding")
+			io.WriteString(w, "ok")
+		}))
+		defer upstream.Close()
+
+		target, err := url.Parse(upstream.URL)
+		require.NoError(t, err)
+
+		proxy := xhttp.NewReverseProxy(xhttp.ProxyOptions{
+			Upstreams: []xhttp.Upstream{{URL: target, Timeout: xhttp.TimeoutOptions{Initial: time.Minute}}},
+		})
+		defer proxy.Close()
+
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, []string{"a=1", "b=2"}, w.Header().Values("Set-Cookie"))
+		require.Equal(t, []string{"Accept", "Accept-Encoding"}, w.Header().Values("Vary"))
+	})
+}
@@ -0,0 +1,267 @@
+package xhttp
+
+import (
+	"cmp"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davidmdm/x/xsync"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit requests, and refills at a steady rate of Limit requests per Window.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows Limit requests over any Window, approximated by weighting the previous fixed window.
+	SlidingWindow
+)
+
+type RateLimitOptions struct {
+	// Limit is the number of requests allowed per Window. Required.
+	Limit int
+	// Window is the period over which Limit applies. Defaults to one second.
+	Window time.Duration
+	// Algorithm is the rate limiting algorithm. Defaults to TokenBucket.
+	Algorithm RateLimitAlgorithm
+	// Key returns the identity of the client making the request. Defaults to RemoteIPKey.
+	Key func(*http.Request) string
+	// MaxKeys bounds the number of clients tracked at once. Once exceeded, the state of idle clients is dropped
+	// first, then that of the least recently seen clients, down to 90% of MaxKeys. Defaults to 10000.
+	MaxKeys int
+	// Handler is served when a request is rate limited. Defaults to a 429 Too Many Requests response.
+	Handler http.Handler
+	// Clock provides the time used to refill and expire the limits of clients. Defaults to the system clock.
+	Clock Clock
+}
+
+// RemoteIPKey identifies clients by the IP address of the connection.
+func RemoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey identifies clients by the value of a request header, such as an API key or the subject of an
+// authenticated request set by an upstream proxy. Requests without the header are identified by their remote IP.
+func HeaderKey(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if value := r.Header.Get(name); value != "" {
+			return name + ":" + value
+		}
+		return RemoteIPKey(r)
+	}
+}
+
+// RateLimitHandler limits the rate of requests each client can make to handler. Every response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and limited requests also carry a Retry-After
+// header. Reset and Retry-After are expressed in seconds.
+func RateLimitHandler(handler http.Handler, opts RateLimitOptions) http.Handler {
+	if opts.Limit <= 0 {
+		return handler
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Second
+	}
+	if opts.Key == nil {
+		opts.Key = RemoteIPKey
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 10000
+	}
+	if opts.Handler == nil {
+		opts.Handler = http.HandlerFunc(defaultRateLimitHandler)
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	limiter := &rateLimiter{RateLimitOptions: opts}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := limiter.allow(opts.Key(r), opts.Clock.Now())
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(opts.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.remaining))
+		w.Header().Set("RateLimit-Reset", seconds(decision.reset))
+
+		if !decision.allowed {
+			w.Header().Set("Retry-After", seconds(decision.retryAfter))
+			opts.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit is the middleware form of RateLimitHandler.
+func RateLimit(opts RateLimitOptions) Middleware {
+	return Named("xhttp.RateLimit", func(handler http.Handler) http.Handler {
+		return RateLimitHandler(handler, opts)
+	})
+}
+
+func defaultRateLimitHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+type rateDecision struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+type rateLimiter struct {
+	RateLimitOptions
+
+	states xsync.Map[string, *rateState]
+	count  atomic.Int64
+	sweep  sync.Mutex
+}
+
+type rateState struct {
+	// lastSeen is the time of the client's last request in Unix nanoseconds. It is read without locking mu.
+	lastSeen atomic.Int64
+
+	mu sync.Mutex
+
+	// token bucket
+	tokens   float64
+	refilled time.Time
+
+	// sliding window
+	windowStart time.Time
+	previous    int
+	current     int
+}
+
+func (limiter *rateLimiter) allow(key string, now time.Time) rateDecision {
+	state, ok := limiter.states.Load(key)
+	if !ok {
+		fresh := &rateState{tokens: float64(limiter.Limit), refilled: now, windowStart: now}
+		fresh.lastSeen.Store(now.UnixNano())
+		if state, ok = limiter.states.LoadOrStore(key, fresh); !ok {
+			if limiter.count.Add(1) > int64(limiter.MaxKeys) {
+				limiter.evict(key, now)
+			}
+		}
+	}
+
+	state.lastSeen.Store(now.UnixNano())
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if limiter.Algorithm == SlidingWindow {
+		return limiter.slidingWindow(state, now)
+	}
+	return limiter.tokenBucket(state, now)
+}
+
+func (limiter *rateLimiter) tokenBucket(state *rateState, now time.Time) (decision rateDecision) {
+	limit := float64(limiter.Limit)
+	rate := limit / limiter.Window.Seconds()
+
+	if elapsed := now.Sub(state.refilled).Seconds(); elapsed > 0 {
+		state.tokens = math.Min(limit, state.tokens+elapsed*rate)
+		state.refilled = now
+	}
+
+	if state.tokens >= 1 {
+		state.tokens--
+		decision.allowed = true
+	} else {
+		decision.retryAfter = time.Duration((1 - state.tokens) / rate * float64(time.Second))
+	}
+
+	decision.remaining = int(state.tokens)
+	decision.reset = time.Duration((limit - state.tokens) / rate * float64(time.Second))
+
+	return decision
+}
+
+func (limiter *rateLimiter) slidingWindow(state *rateState, now time.Time) (decision rateDecision) {
+	if elapsed := now.Sub(state.windowStart); elapsed >= limiter.Window {
+		windows := elapsed / limiter.Window
+		if windows == 1 {
+			state.previous = state.current
+		} else {
+			state.previous = 0
+		}
+		state.current = 0
+		state.windowStart = state.windowStart.Add(windows * limiter.Window)
+	}
+
+	elapsed := now.Sub(state.windowStart)
+	weight := 1 - elapsed.Seconds()/limiter.Window.Seconds()
+	estimate := float64(state.previous)*weight + float64(state.current)
+
+	decision.reset = limiter.Window - elapsed
+
+	if estimate+1 <= float64(limiter.Limit) {
+		state.current++
+		estimate++
+		decision.allowed = true
+	} else {
+		decision.retryAfter = limiter.Window - elapsed
+	}
+
+	decision.remaining = max(limiter.Limit-int(math.Ceil(estimate)), 0)
+
+	return decision
+}
+
+// evict bounds the memory used by the limiter. It drops states in a batch down to 90% of MaxKeys, such that the cost
+// of scanning every state is shared by the clients added until the next eviction. States are dropped from the least
+// recently seen: all states that have been idle for a full window, as they are equivalent to fresh states, then as
+// many as needed. The state of active clients is therefore the last to go. The state of the client that triggered
+// the eviction is kept.
+func (limiter *rateLimiter) evict(current string, now time.Time) {
+	if !limiter.sweep.TryLock() {
+		return
+	}
+	defer limiter.sweep.Unlock()
+
+	type entry struct {
+		key      string
+		state    *rateState
+		lastSeen int64
+	}
+
+	var entries []entry
+	for key, state := range limiter.states.All() {
+		if key != current {
+			entries = append(entries, entry{key: key, state: state, lastSeen: state.lastSeen.Load()})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int { return cmp.Compare(a.lastSeen, b.lastSeen) })
+
+	var (
+		target = int64(limiter.MaxKeys - max(limiter.MaxKeys/10, 1))
+		idle   = now.Add(-limiter.Window).UnixNano()
+	)
+
+	for _, e := range entries {
+		if e.lastSeen > idle && limiter.count.Load() <= target {
+			return
+		}
+		if limiter.states.CompareAndDelete(e.key, e.state) {
+			limiter.count.Add(-1)
+		}
+	}
+}
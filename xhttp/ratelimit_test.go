@@ -0,0 +1,213 @@
+package xhttp_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/davidmdm/x/xhttp/xhttptest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	type Response struct {
+		Status     int
+		Remaining  string
+		RetryAfter string
+	}
+
+	cases := []struct {
+		Name      string
+		Options   xhttp.RateLimitOptions
+		Requests  []string
+		Advance   time.Duration
+		After     []string
+		Responses []Response
+	}{
+		{
+			Name:     "token bucket",
+			Options:  xhttp.RateLimitOptions{Limit: 3, Window: time.Hour},
+			Requests: []string{"", "", "", ""},
+			Responses: []Response{
+				{Status: 200, Remaining: "2"},
+				{Status: 200, Remaining: "1"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "1200"},
+			},
+		},
+		{
+			Name:     "token bucket refills",
+			Options:  xhttp.RateLimitOptions{Limit: 2, Window: 200 * time.Millisecond},
+			Requests: []string{"", "", ""},
+			Advance:  120 * time.Millisecond,
+			After:    []string{"", ""},
+			Responses: []Response{
+				{Status: 200, Remaining: "1"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "1"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "1"},
+			},
+		},
+		{
+			Name:     "sliding window",
+			Options:  xhttp.RateLimitOptions{Limit: 2, Window: time.Hour, Algorithm: xhttp.SlidingWindow},
+			Requests: []string{"", "", ""},
+			Responses: []Response{
+				{Status: 200, Remaining: "1"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "3600"},
+			},
+		},
+		{
+			Name:     "sliding window weighs previous window",
+			Options:  xhttp.RateLimitOptions{Limit: 2, Window: 200 * time.Millisecond, Algorithm: xhttp.SlidingWindow},
+			Requests: []string{"", ""},
+			Advance:  210 * time.Millisecond,
+			After:    []string{""},
+			Responses: []Response{
+				{Status: 200, Remaining: "1"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "1"},
+			},
+		},
+		{
+			Name:     "header key",
+			Options:  xhttp.RateLimitOptions{Limit: 1, Window: time.Hour, Key: xhttp.HeaderKey("X-Api-Key")},
+			Requests: []string{"a", "b", "a", ""},
+			Responses: []Response{
+				{Status: 200, Remaining: "0"},
+				{Status: 200, Remaining: "0"},
+				{Status: 429, Remaining: "0", RetryAfter: "3600"},
+				{Status: 200, Remaining: "0"},
+			},
+		},
+		{
+			Name: "custom handler",
+			Options: xhttp.RateLimitOptions{
+				Limit:  1,
+				Window: time.Hour,
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(503)
+				}),
+			},
+			Requests: []string{"", ""},
+			Responses: []Response{
+				{Status: 200, Remaining: "0"},
+				{Status: 503, Remaining: "0", RetryAfter: "3600"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			clock := xhttptest.NewClock(time.Now())
+			tc.Options.Clock = clock
+
+			handler := xhttp.RateLimitHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+				tc.Options,
+			)
+
+			var responses []Response
+
+			do := func(keys []string) {
+				for _, key := range keys {
+					r := httptest.NewRequest("GET", "/", nil)
+					if key != "" {
+						r.Header.Set("X-Api-Key", key)
+					}
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, r)
+
+					require.Equal(t, strconv.Itoa(tc.Options.Limit), w.Header().Get("RateLimit-Limit"))
+					require.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+
+					responses = append(responses, Response{
+						Status:     w.Code,
+						Remaining:  w.Header().Get("RateLimit-Remaining"),
+						RetryAfter: w.Header().Get("Retry-After"),
+					})
+				}
+			}
+
+			do(tc.Requests)
+			clock.Advance(tc.Advance)
+			do(tc.After)
+
+			require.Equal(t, tc.Responses, responses)
+		})
+	}
+}
+
+func TestRateLimitHandlerMaxKeys(t *testing.T) {
+	handler := xhttp.RateLimitHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		xhttp.RateLimitOptions{
+			Limit:   1,
+			Window:  time.Hour,
+			Key:     xhttp.HeaderKey("X-Api-Key"),
+			MaxKeys: 2,
+		},
+	)
+
+	do := func(key string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Api-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, key := range keys {
+		require.Equal(t, 200, do(key))
+	}
+
+	// Only the state of the keys still tracked remembers that their token was spent.
+	var limited int
+	for _, key := range keys {
+		if do(key) == 429 {
+			limited++
+		}
+	}
+	require.LessOrEqual(t, limited, 2)
+}
+
+func TestRateLimitHandlerMaxKeysKeepsActiveClients(t *testing.T) {
+	handler := xhttp.RateLimitHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		xhttp.RateLimitOptions{
+			Limit:   1,
+			Window:  time.Hour,
+			Key:     xhttp.HeaderKey("X-Api-Key"),
+			MaxKeys: 10,
+		},
+	)
+
+	do := func(key string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Api-Key", key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	victims := []string{"victim-a", "victim-b", "victim-c", "victim-d", "victim-e"}
+	for _, victim := range victims {
+		require.Equal(t, 200, do(victim))
+		require.Equal(t, 429, do(victim))
+	}
+
+	// Churning through many more keys than MaxKeys does not reset the limit of clients that keep making requests.
+	for i := range 100 {
+		require.Equal(t, 200, do(fmt.Sprintf("churn-%d", i)))
+		for _, victim := range victims {
+			require.Equal(t, 429, do(victim), "%s lost its limit after %d keys", victim, i+1)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package xhttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Recording is a snapshot of what a handler wrote to its response.
+type Recording struct {
+	// Status is the status code sent to the client. If the handler never wrote a header explicitly, it is
+	// the implicit http.StatusOK sent by the server on first write or on return.
+	Status int
+	// Size is the number of body bytes successfully written.
+	Size int64
+	// TimeToFirstByte is the time elapsed between the start of the request and the moment the response
+	// headers were committed. It is zero if the response was never committed.
+	TimeToFirstByte time.Duration
+	// Duration is the time elapsed between the start of the request and the moment the recording was taken.
+	Duration time.Duration
+	// Hijacked reports whether the underlying connection was taken over by the handler.
+	Hijacked bool
+	// Committed reports whether the response headers were sent, after which the response can no longer be replaced.
+	Committed bool
+}
+
+// RecordingWriter is an http.ResponseWriter that records the status, size and timings of a response.
+// It preserves the features reachable via http.ResponseController (Flush, Hijack, SetReadDeadline,
+// SetWriteDeadline, EnableFullDuplex) by implementing Unwrap, and is safe for concurrent use.
+type RecordingWriter struct {
+	http.ResponseWriter
+
+	start time.Time
+
+	mu        sync.Mutex
+	status    int
+	size      int64
+	firstByte time.Time
+	hijacked  bool
+}
+
+// NewRecordingWriter wraps w in a RecordingWriter whose timings start now.
+func NewRecordingWriter(w http.ResponseWriter) *RecordingWriter {
+	return &RecordingWriter{
+		ResponseWriter: w,
+		start:          time.Now(),
+	}
+}
+
+func (w *RecordingWriter) commit(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.firstByte = time.Now()
+}
+
+func (w *RecordingWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	// Informational headers other than 101 Switching Protocols may be followed by a final status.
+	if status >= 200 || status == http.StatusSwitchingProtocols {
+		w.commit(status)
+	}
+	w.mu.Unlock()
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *RecordingWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	w.commit(http.StatusOK)
+	w.mu.Unlock()
+
+	n, err := w.ResponseWriter.Write(data)
+
+	w.mu.Lock()
+	w.size += int64(n)
+	w.mu.Unlock()
+
+	return n, err
+}
+
+// Flush satisfies the http.Flusher interface. Flushing errors are ignored, use FlushError to observe them.
+func (w *RecordingWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError flushes the underlying writer via its http.ResponseController. Flushing commits the response.
+func (w *RecordingWriter) FlushError() error {
+	w.mu.Lock()
+	w.commit(http.StatusOK)
+	w.mu.Unlock()
+
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack satisfies the http.Hijacker interface by hijacking the underlying writer via its http.ResponseController.
+func (w *RecordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.mu.Lock()
+		w.hijacked = true
+		w.mu.Unlock()
+	}
+	return conn, rw, err
+}
+
+// Recording returns a snapshot of the response as recorded so far.
+func (w *RecordingWriter) Recording() Recording {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec := Recording{
+		Status:   w.status,
+		Size:     w.size,
+		Duration: time.Since(w.start),
+		Hijacked: w.hijacked,
+	}
+	if !w.firstByte.IsZero() {
+		rec.Committed = true
+		rec.TimeToFirstByte = w.firstByte.Sub(w.start)
+	}
+	if rec.Status == 0 && !rec.Hijacked {
+		rec.Status = http.StatusOK
+	}
+	return rec
+}
+
+// Unwrap satisfies the implicit http.rwUnwrapper interface.
+func (w *RecordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RecordingHandler wraps handler such that its response is recorded. Once handler returns, report is called
+// with the request and the final recording of its response.
+func RecordingHandler(handler http.Handler, report func(*http.Request, Recording)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := NewRecordingWriter(w)
+		defer func() { report(r, rw.Recording()) }()
+		handler.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,193 @@
+package xhttp_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingHandler(t *testing.T) {
+	cases := []struct {
+		Name           string
+		Handler        http.HandlerFunc
+		ExpectedStatus int
+		ExpectedSize   int64
+		ExpectedBody   string
+	}{
+		{
+			Name: "implicit status",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "hello world")
+			},
+			ExpectedStatus: 200,
+			ExpectedSize:   11,
+			ExpectedBody:   "hello world",
+		},
+		{
+			Name:           "no write",
+			Handler:        func(w http.ResponseWriter, r *http.Request) {},
+			ExpectedStatus: 200,
+			ExpectedSize:   0,
+		},
+		{
+			Name: "explicit status",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				io.WriteString(w, "tea")
+			},
+			ExpectedStatus: 418,
+			ExpectedSize:   3,
+			ExpectedBody:   "tea",
+		},
+		{
+			Name: "informational header",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusCreated)
+			},
+			ExpectedStatus: 201,
+			ExpectedSize:   0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			recordings := make(chan xhttp.Recording, 1)
+
+			server := httptest.NewServer(xhttp.RecordingHandler(tc.Handler, func(r *http.Request, rec xhttp.Recording) {
+				recordings <- rec
+			}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			require.Equal(t, tc.ExpectedBody, string(body))
+
+			rec := <-recordings
+			require.Equal(t, tc.ExpectedStatus, rec.Status)
+			require.Equal(t, tc.ExpectedSize, rec.Size)
+			require.LessOrEqual(t, rec.TimeToFirstByte, rec.Duration)
+		})
+	}
+}
+
+func TestRecordingWriterTimings(t *testing.T) {
+	recordings := make(chan xhttp.Recording, 1)
+
+	handler := xhttp.RecordingHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			io.WriteString(w, "first")
+			time.Sleep(10 * time.Millisecond)
+			io.WriteString(w, "second")
+		}),
+		func(r *http.Request, rec xhttp.Recording) { recordings <- rec },
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	rec := <-recordings
+	require.GreaterOrEqual(t, rec.TimeToFirstByte, 10*time.Millisecond)
+	require.GreaterOrEqual(t, rec.Duration, 20*time.Millisecond)
+	require.Greater(t, rec.Duration, rec.TimeToFirstByte)
+	require.EqualValues(t, 11, rec.Size)
+}
+
+func TestRecordingWriterPreservesResponseController(t *testing.T) {
+	t.Run("flush", func(t *testing.T) {
+		flushed := make(chan struct{})
+		release := make(chan struct{})
+
+		handler := xhttp.RecordingHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "partial")
+				require.NoError(t, http.NewResponseController(w).Flush())
+				close(flushed)
+				<-release
+			}),
+			func(*http.Request, xhttp.Recording) {},
+		)
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		<-flushed
+
+		buf := make([]byte, len("partial"))
+		_, err = io.ReadFull(resp.Body, buf)
+		require.NoError(t, err)
+		require.Equal(t, "partial", string(buf))
+
+		close(release)
+	})
+
+	t.Run("hijack", func(t *testing.T) {
+		recordings := make(chan xhttp.Recording, 1)
+
+		handler := xhttp.RecordingHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, rw, err := http.NewResponseController(w).Hijack()
+				require.NoError(t, err)
+				defer conn.Close()
+
+				rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+				rw.Flush()
+			}),
+			func(r *http.Request, rec xhttp.Recording) { recordings <- rec },
+		)
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "hijacked", string(body))
+
+		require.True(t, (<-recordings).Hijacked)
+	})
+
+	t.Run("write deadline", func(t *testing.T) {
+		handler := xhttp.RecordingHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Second)))
+			}),
+			func(*http.Request, xhttp.Recording) {},
+		)
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+}
@@ -0,0 +1,134 @@
+package xhttp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/davidmdm/x/xruntime"
+)
+
+// PanicError is a panic recovered while serving a request, along with the stack of the goroutine that panicked.
+type PanicError struct {
+	Value any
+	Stack xruntime.Stack
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", err.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (err *PanicError) Unwrap() error {
+	if err, ok := err.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+type RecoverOptions struct {
+	// Report is called with every panic recovered while serving a request. Defaults to logging the panic and its stack
+	// with the standard logger, as the http.Server does.
+	Report func(*http.Request, *PanicError)
+	// Handler is served after a panic if no response had been committed yet. Defaults to a 500 Internal Server Error.
+	Handler http.Handler
+}
+
+// Recover recovers panics raised by handler, reports them, and serves a 500 response if the handler had not
+// committed a response yet. Panics with http.ErrAbortHandler are not recovered, as they are used to abort a response.
+//
+// Panics raised on the goroutine spawned by a TimeoutHandler are recovered as well: they are forwarded to the goroutine
+// serving the request. If the TimeoutHandler has already responded when the panic occurs, the panic is still reported
+// but no response is written.
+func Recover(handler http.Handler, opts RecoverOptions) http.Handler {
+	if opts.Report == nil {
+		opts.Report = defaultReport
+	}
+	if opts.Handler == nil {
+		opts.Handler = http.HandlerFunc(defaultRecoverHandler)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := NewRecordingWriter(w)
+
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			err, ok := value.(*PanicError)
+			if !ok {
+				err = &PanicError{Value: value, Stack: xruntime.CallStack(0)}
+			}
+
+			opts.Report(r, err)
+
+			if recording := rw.Recording(); !recording.Committed && !recording.Hijacked {
+				opts.Handler.ServeHTTP(w, r)
+			}
+		}()
+
+		ctx := context.WithValue(r.Context(), reporterKey{}, func(err *PanicError) { opts.Report(r, err) })
+
+		handler.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// RecoverMiddleware is the middleware form of Recover.
+func RecoverMiddleware(opts RecoverOptions) Middleware {
+	return Named("xhttp.Recover", func(handler http.Handler) http.Handler {
+		return Recover(handler, opts)
+	})
+}
+
+func defaultReport(r *http.Request, err *PanicError) {
+	log.Printf("http: panic serving %s: %v\n%s", r.RemoteAddr, err.Value, err.Stack)
+}
+
+func defaultRecoverHandler(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+type reporterKey struct{}
+
+// spawn serves r with handler on a new goroutine and calls done once the handler returns. If the handler panics, the
+// panic is sent to panics instead, such that it can be raised again on the goroutine serving the request, where the
+// http.Server or Recover can recover it. If that goroutine has already returned, the panic is reported to the
+// enclosing Recover handler if any, and raised again otherwise. Late panics with http.ErrAbortHandler are dropped.
+func spawn(handler http.Handler, w http.ResponseWriter, r *http.Request, panics chan<- any, returned <-chan struct{}, done func()) {
+	go func() {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+
+			if _, ok := value.(*PanicError); !ok && value != http.ErrAbortHandler {
+				value = &PanicError{Value: value, Stack: xruntime.CallStack(0)}
+			}
+
+			select {
+			case panics <- value:
+				return
+			case <-returned:
+			}
+
+			if value == http.ErrAbortHandler {
+				return
+			}
+			if report, ok := r.Context().Value(reporterKey{}).(func(*PanicError)); ok {
+				report(value.(*PanicError))
+				return
+			}
+			panic(value)
+		}()
+
+		handler.ServeHTTP(w, r)
+		done()
+	}()
+}
@@ -0,0 +1,158 @@
+package xhttp_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	cases := []struct {
+		Name    string
+		Handler http.Handler
+		Status  int
+		Body    string
+	}{
+		{
+			Name:    "panic before write",
+			Handler: panicking,
+			Status:  500,
+			Body:    "Internal Server Error\n",
+		},
+		{
+			Name: "panic after write",
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(202)
+				w.Write([]byte("partial"))
+				panic("boom")
+			}),
+			Status: 202,
+			Body:   "partial",
+		},
+		{
+			Name:    "panic within timeout handler",
+			Handler: xhttp.TimeoutHandler(panicking, xhttp.TimeoutOptions{Initial: time.Minute}),
+			Status:  500,
+			Body:    "Internal Server Error\n",
+		},
+		{
+			Name:    "panic within streaming timeout handler",
+			Handler: xhttp.TimeoutHandler(panicking, xhttp.TimeoutOptions{Rolling: time.Minute, Stream: &xhttp.StreamOptions{}}),
+			Status:  500,
+			Body:    "Internal Server Error\n",
+		},
+		{
+			Name:    "panic within buffered timeout handler",
+			Handler: xhttp.TimeoutHandler(panicking, xhttp.TimeoutOptions{Initial: time.Minute, BufferSize: 1024}),
+			Status:  500,
+			Body:    "Internal Server Error\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var reported []*xhttp.PanicError
+
+			handler := xhttp.Recover(tc.Handler, xhttp.RecoverOptions{
+				Report: func(r *http.Request, err *xhttp.PanicError) { reported = append(reported, err) },
+			})
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			require.Equal(t, tc.Status, w.Code)
+			require.Equal(t, tc.Body, w.Body.String())
+
+			require.Len(t, reported, 1)
+			require.Equal(t, "boom", reported[0].Value)
+			require.EqualError(t, reported[0], "panic: boom")
+			require.True(t, hasFrame(reported[0], "xhttp_test.TestRecover"), reported[0].Stack.String())
+		})
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	handler := xhttp.Recover(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }),
+		xhttp.RecoverOptions{Report: func(*http.Request, *xhttp.PanicError) { t.Fatal("unexpected report") }},
+	)
+
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
+
+func TestTimeoutHandlerPanics(t *testing.T) {
+	t.Run("forwards panic to the serving goroutine", func(t *testing.T) {
+		var result error
+
+		handler := xhttp.TimeoutHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(errors.New("boom")) }),
+			xhttp.TimeoutOptions{
+				Initial:    time.Minute,
+				OnComplete: func(r *http.Request, err error) { result = err },
+			},
+		)
+
+		func() {
+			defer func() {
+				err, ok := recover().(*xhttp.PanicError)
+				require.True(t, ok)
+				require.EqualError(t, err, "panic: boom")
+				require.True(t, hasFrame(err, "xhttp_test.TestTimeoutHandlerPanics"), err.Stack.String())
+			}()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+
+		var panicErr *xhttp.PanicError
+		require.ErrorAs(t, result, &panicErr)
+	})
+
+	t.Run("reports panics after the timeout", func(t *testing.T) {
+		reported := make(chan *xhttp.PanicError, 1)
+
+		handler := xhttp.Recover(
+			xhttp.TimeoutHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					<-r.Context().Done()
+					panic("late")
+				}),
+				xhttp.TimeoutOptions{Initial: 10 * time.Millisecond},
+			),
+			xhttp.RecoverOptions{
+				Report: func(r *http.Request, err *xhttp.PanicError) { reported <- err },
+			},
+		)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		require.Equal(t, 503, w.Code)
+
+		select {
+		case err := <-reported:
+			require.Equal(t, "late", err.Value)
+		case <-time.After(time.Second):
+			t.Fatal("late panic was not reported")
+		}
+	})
+}
+
+func hasFrame(err *xhttp.PanicError, function string) bool {
+	for _, frame := range err.Stack.Frames {
+		if strings.Contains(frame.Function, function) {
+			return true
+		}
+	}
+	return false
+}
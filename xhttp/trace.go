@@ -0,0 +1,222 @@
+package xhttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidTraceparent is returned by ParseTraceparent when a header does not conform to the W3C Trace Context format.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceContext is the W3C Trace Context of a request: https://www.w3.org/TR/trace-context/
+type TraceContext struct {
+	TraceID [16]byte
+	// SpanID is the id of the span of the current service. It is sent as the parent-id of outbound requests.
+	SpanID [8]byte
+	// ParentID is the id of the span of the caller, if any.
+	ParentID [8]byte
+	Flags    byte
+	// State is the opaque vendor specific tracestate, forwarded as is.
+	State string
+}
+
+// FlagSampled is the trace flag indicating that the caller may have recorded trace data.
+const FlagSampled byte = 0x01
+
+// Sampled reports whether the sampled flag is set.
+func (trace TraceContext) Sampled() bool {
+	return trace.Flags&FlagSampled != 0
+}
+
+// Traceparent formats the trace as a traceparent header identifying the current span as the parent.
+func (trace TraceContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(trace.TraceID[:]) + "-" + hex.EncodeToString(trace.SpanID[:]) + "-" + hex.EncodeToString([]byte{trace.Flags})
+}
+
+// ParseTraceparent parses a traceparent header. The returned trace's ParentID is the parent-id of the header, and its
+// SpanID is left empty. Headers of future versions are accepted as long as they start with the fields of version 00.
+func ParseTraceparent(value string) (trace TraceContext, err error) {
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	version, ok := decodeLowerHex(value[:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	traceID, ok := decodeLowerHex(value[3:35])
+	if !ok || isZero(traceID) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	parentID, ok := decodeLowerHex(value[36:52])
+	if !ok || isZero(parentID) {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+	flags, ok := decodeLowerHex(value[53:55])
+	if !ok {
+		return TraceContext{}, ErrInvalidTraceparent
+	}
+
+	copy(trace.TraceID[:], traceID)
+	copy(trace.ParentID[:], parentID)
+	trace.Flags = flags[0]
+
+	return trace, nil
+}
+
+func decodeLowerHex(value string) ([]byte, bool) {
+	if strings.ToLower(value) != value {
+		return nil, false
+	}
+	data, err := hex.DecodeString(value)
+	return data, err == nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type requestIDKey struct{}
+
+type traceKey struct{}
+
+// RequestID returns the request ID stored in ctx by a TraceHandler or WithRequestID.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// TraceFromContext returns the TraceContext stored in ctx by a TraceHandler or WithTrace.
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	trace, ok := ctx.Value(traceKey{}).(TraceContext)
+	return trace, ok
+}
+
+// WithTrace returns a copy of ctx carrying the trace.
+func WithTrace(ctx context.Context, trace TraceContext) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+type TraceOptions struct {
+	// RequestIDHeader is the header carrying the request ID. Defaults to X-Request-Id.
+	RequestIDHeader string
+	// NewRequestID generates the ID of requests that do not carry a valid one. Defaults to 26 random base32 characters.
+	NewRequestID func() string
+	// Transport is the underlying round tripper of a TraceTransport. Defaults to http.DefaultTransport.
+	// It is not used by the TraceHandler.
+	Transport http.RoundTripper
+}
+
+func (opts *TraceOptions) defaults() {
+	if opts.RequestIDHeader == "" {
+		opts.RequestIDHeader = "X-Request-Id"
+	}
+	if opts.NewRequestID == nil {
+		opts.NewRequestID = rand.Text
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+}
+
+// TraceHandler stores a request ID and a TraceContext in the context of each request, where they can be read back with
+// RequestID and TraceFromContext for logging. The request ID is taken from the request if it carries a valid one, and
+// generated otherwise. It is echoed in the response headers. If the request carries a valid traceparent header, the
+// trace is continued with a new span for this service. Otherwise a new trace is started.
+func TraceHandler(handler http.Handler, opts TraceOptions) http.Handler {
+	opts.defaults()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(opts.RequestIDHeader)
+		if !validRequestID(id) {
+			id = opts.NewRequestID()
+		}
+		w.Header().Set(opts.RequestIDHeader, id)
+
+		trace, err := ParseTraceparent(r.Header.Get("Traceparent"))
+		if err == nil {
+			trace.State = strings.Join(r.Header.Values("Tracestate"), ",")
+		} else {
+			trace = TraceContext{Flags: FlagSampled}
+			rand.Read(trace.TraceID[:])
+		}
+		rand.Read(trace.SpanID[:])
+
+		ctx := WithTrace(WithRequestID(r.Context(), id), trace)
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Trace is the middleware form of TraceHandler.
+func Trace(opts TraceOptions) Middleware {
+	return Named("xhttp.Trace", func(handler http.Handler) http.Handler {
+		return TraceHandler(handler, opts)
+	})
+}
+
+// validRequestID accepts IDs of up to 128 visible ASCII characters, such that untrusted IDs are safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceTransport is an http.RoundTripper that forwards the request ID and TraceContext found in the context of
+// outbound requests, as stored by a TraceHandler. Headers already set on a request are left untouched.
+type TraceTransport struct {
+	opts TraceOptions
+}
+
+var _ http.RoundTripper = (*TraceTransport)(nil)
+
+func NewTraceTransport(opts TraceOptions) *TraceTransport {
+	opts.defaults()
+	return &TraceTransport{opts: opts}
+}
+
+func (transport *TraceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	header := r.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	if id := RequestID(r.Context()); id != "" && header.Get(transport.opts.RequestIDHeader) == "" {
+		header.Set(transport.opts.RequestIDHeader, id)
+	}
+
+	if trace, ok := TraceFromContext(r.Context()); ok && header.Get("Traceparent") == "" {
+		header.Set("Traceparent", trace.Traceparent())
+		header.Del("Tracestate")
+		if trace.State != "" {
+			header.Set("Tracestate", trace.State)
+		}
+	}
+
+	// RoundTrippers must not modify the request, hence the shallow copy.
+	outbound := *r
+	outbound.Header = header
+
+	return transport.opts.Transport.RoundTrip(&outbound)
+}
@@ -0,0 +1,209 @@
+package xhttp_test
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Value    string
+		TraceID  string
+		ParentID string
+		Sampled  bool
+		Err      error
+	}{
+		{
+			Name:     "valid",
+			Value:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			ParentID: "00f067aa0ba902b7",
+			Sampled:  true,
+		},
+		{
+			Name:     "not sampled",
+			Value:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			ParentID: "00f067aa0ba902b7",
+		},
+		{
+			Name:     "future version with extra fields",
+			Value:    "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds",
+			TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			ParentID: "00f067aa0ba902b7",
+			Sampled:  true,
+		},
+		{
+			Name:  "version 00 with extra fields",
+			Value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+		{
+			Name:  "invalid version",
+			Value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+		{
+			Name:  "uppercase",
+			Value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+		{
+			Name:  "zero trace id",
+			Value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+		{
+			Name:  "zero parent id",
+			Value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+		{
+			Name:  "empty",
+			Value: "",
+			Err:   xhttp.ErrInvalidTraceparent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			trace, err := xhttp.ParseTraceparent(tc.Value)
+			if tc.Err != nil {
+				require.ErrorIs(t, err, tc.Err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.TraceID, hex.EncodeToString(trace.TraceID[:]))
+			require.Equal(t, tc.ParentID, hex.EncodeToString(trace.ParentID[:]))
+			require.Equal(t, tc.Sampled, trace.Sampled())
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	type Outbound struct {
+		RequestID   string
+		Traceparent string
+		Tracestate  string
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		w.Header().Set("Traceparent", r.Header.Get("Traceparent"))
+		w.Header().Set("Tracestate", r.Header.Get("Tracestate"))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: xhttp.NewTraceTransport(xhttp.TraceOptions{})}
+
+	var (
+		requestID string
+		trace     xhttp.TraceContext
+		outbound  Outbound
+	)
+
+	handler := xhttp.TraceHandler(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID = xhttp.RequestID(r.Context())
+			trace, _ = xhttp.TraceFromContext(r.Context())
+
+			req, err := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+			require.NoError(t, err)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			outbound = Outbound{
+				RequestID:   resp.Header.Get("X-Request-Id"),
+				Traceparent: resp.Header.Get("Traceparent"),
+				Tracestate:  resp.Header.Get("Tracestate"),
+			}
+		}),
+		xhttp.TraceOptions{NewRequestID: func() string { return "generated" }},
+	)
+
+	t.Run("continues incoming trace", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-Id", "incoming")
+		r.Header.Set("Traceparent", traceparent)
+		r.Header.Add("Tracestate", "rojo=00f067aa0ba902b7")
+		r.Header.Add("Tracestate", "congo=t61rcWkgMzE")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, "incoming", w.Header().Get("X-Request-Id"))
+		require.Equal(t, "incoming", requestID)
+
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(trace.TraceID[:]))
+		require.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(trace.ParentID[:]))
+		require.NotEqual(t, trace.ParentID, trace.SpanID)
+		require.True(t, trace.Sampled())
+
+		require.Equal(
+			t,
+			Outbound{
+				RequestID:   "incoming",
+				Traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-" + hex.EncodeToString(trace.SpanID[:]) + "-01",
+				Tracestate:  "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE",
+			},
+			outbound,
+		)
+	})
+
+	t.Run("starts new trace", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-Id", "invalid id")
+		r.Header.Set("Traceparent", "garbage")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		require.Equal(t, "generated", w.Header().Get("X-Request-Id"))
+		require.Equal(t, "generated", requestID)
+
+		require.NotEqual(t, [16]byte{}, trace.TraceID)
+		require.Equal(t, [8]byte{}, trace.ParentID)
+
+		require.Equal(
+			t,
+			Outbound{RequestID: "generated", Traceparent: trace.Traceparent()},
+			outbound,
+		)
+		require.True(t, strings.HasPrefix(outbound.Traceparent, "00-"+hex.EncodeToString(trace.TraceID[:])))
+	})
+}
+
+func TestTraceTransportKeepsExplicitHeaders(t *testing.T) {
+	var header http.Header
+
+	transport := xhttp.NewTraceTransport(xhttp.TraceOptions{
+		Transport: RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			header = r.Header
+			return httptest.NewRecorder().Result(), nil
+		}),
+	})
+
+	ctx := xhttp.WithRequestID(t.Context(), "from-context")
+
+	r, err := http.NewRequestWithContext(ctx, "GET", "http://example.test", nil)
+	require.NoError(t, err)
+	r.Header.Set("X-Request-Id", "explicit")
+
+	_, err = transport.RoundTrip(r)
+	require.NoError(t, err)
+
+	require.Equal(t, "explicit", header.Get("X-Request-Id"))
+	require.Empty(t, header.Get("Traceparent"))
+	require.Equal(t, http.Header{"X-Request-Id": {"explicit"}}, r.Header)
+}
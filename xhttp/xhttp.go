@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -15,10 +16,68 @@ type TimeoutOptions struct {
 	Initial time.Duration
 	Rolling time.Duration
 	Handler http.Handler
+	// Stream enables the streaming mode for long-lived responses. See StreamOptions.
+	Stream *StreamOptions
+	// BufferSize enables the buffered mode when positive. In buffered mode the handler's response is held in memory
+	// up to BufferSize bytes and only written once the handler returns. If the initial timeout is reached first the
+	// timeout response is served, guaranteeing that the client never receives a partial response. Writes beyond
+	// BufferSize fail with ErrBufferExceeded and the client receives a 500 Internal Server Error. The rolling timeout,
+	// if any, bounds the time allowed to write the buffered response to the connection. Stream is ignored in
+	// buffered mode.
+	BufferSize int
+	// Clock provides the timers used to enforce the timeouts. Defaults to the system clock.
+	Clock Clock
+	// OnComplete, if set, is called once the request has been served. The error is nil if the handler completed
+	// normally, ErrTimeoutBeforeWrite if the initial timeout was reached, ErrTimeoutDuringWrite if the rolling timeout
+	// was reached, ErrBufferExceeded if the buffered response was too large, or a *PanicError if the handler panicked.
+	// It is not called when no timeouts are configured, as the handler is then served as is.
+	OnComplete func(*http.Request, error)
+}
+
+// Clock is the source of time for the TimeoutHandler and the other time sensitive types of this package. It exists so
+// that timeouts can be controlled in tests.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the subset of the *time.Timer API used by the TimeoutHandler.
+type Timer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// StreamOptions configure the streaming mode of the TimeoutHandler, meant for Server-Sent Events, long-polling
+// and chunked responses. In streaming mode flushes reset the rolling timeout as well as writes, a heartbeat can
+// be written whenever the handler is idle, and reaching the rolling timeout ends the response cleanly with a
+// close frame instead of forcibly expiring the connection's write deadline.
+type StreamOptions struct {
+	// HeartbeatInterval is the duration of inactivity after which a heartbeat is written. It should be shorter
+	// than the rolling timeout. Heartbeats only start once the response has been committed by a write or flush.
+	// If zero, no heartbeats are written.
+	HeartbeatInterval time.Duration
+	// Heartbeat writes a keep-alive message. Defaults to an empty Server-Sent Events comment.
+	Heartbeat func(io.Writer) error
+	// Close writes the final frame of the response once the rolling timeout is reached. If nil the response is
+	// simply terminated.
+	Close func(io.Writer) error
+	// CloseTimeout bounds the time allowed for writing the close frame, after which the connection is killed.
+	// Defaults to the rolling timeout.
+	CloseTimeout time.Duration
 }
 
 func TimeoutHandler(handler http.Handler, opts TimeoutOptions) http.Handler {
-	if opts.Initial <= 0 && opts.Rolling <= 0 {
+	if opts.Initial <= 0 && opts.Rolling <= 0 && opts.Stream == nil {
 		return handler
 	}
 	if opts.Initial <= 0 {
@@ -27,6 +86,25 @@ func TimeoutHandler(handler http.Handler, opts TimeoutOptions) http.Handler {
 	if opts.Handler == nil {
 		opts.Handler = http.HandlerFunc(defaultTimeoutHandler)
 	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	if opts.OnComplete == nil {
+		opts.OnComplete = func(*http.Request, error) {}
+	}
+	if opts.BufferSize > 0 && opts.Initial > 0 {
+		return bufferedTimeoutHandler(handler, opts)
+	}
+	if opts.Stream != nil {
+		stream := *opts.Stream
+		if stream.Heartbeat == nil {
+			stream.Heartbeat = defaultHeartbeat
+		}
+		if stream.CloseTimeout <= 0 {
+			stream.CloseTimeout = opts.Rolling
+		}
+		opts.Stream = &stream
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithCancelCause(r.Context())
@@ -34,6 +112,9 @@ func TimeoutHandler(handler http.Handler, opts TimeoutOptions) http.Handler {
 
 		r = r.WithContext(ctx)
 
+		var result error
+		defer func() { opts.OnComplete(r, result) }()
+
 		done := make(chan int, 3)
 
 		tw := timeoutWriter{
@@ -48,14 +129,42 @@ func TimeoutHandler(handler http.Handler, opts TimeoutOptions) http.Handler {
 			Controller:     http.NewResponseController(w),
 		}
 
-		defer time.AfterFunc(opts.Initial, tw.Timeout).Stop()
+		if opts.Stream != nil {
+			tw.mu = new(sync.Mutex)
+			defer tw.stop()
+		}
 
-		go func() {
-			handler.ServeHTTP(&tw, r)
-			done <- writing
-		}()
+		if opts.Initial > 0 {
+			defer opts.Clock.AfterFunc(opts.Initial, tw.Timeout).Stop()
+		}
+
+		panics, returned := make(chan any), make(chan struct{})
+		defer close(returned)
+
+		spawn(handler, &tw, r, panics, returned, func() { done <- writing })
+
+		var state int
+		select {
+		case state = <-done:
+		case value := <-panics:
+			// Let the timeout handler finish its response if it has started, or prevent it from starting.
+			if !tw.state.CompareAndSwap(pending, writing) && tw.state.Load() == timeout {
+				<-done
+			}
+			result, _ = value.(error)
+			panic(value)
+		}
 
-		if state := <-done; state == hung {
+		switch state {
+		case timeout:
+			result = ErrTimeoutBeforeWrite
+		case hung:
+			result = ErrTimeoutDuringWrite
+			cancel(fmt.Errorf("%w: %w", context.Canceled, ErrTimeoutDuringWrite))
+			if opts.Stream != nil {
+				tw.closeStream()
+				return
+			}
 			tw.Controller.Flush()
 			tw.Controller.SetWriteDeadline(time.Now().Add(-time.Second))
 			w.Write(nil)
@@ -76,6 +185,11 @@ func defaultTimeoutHandler(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, defaultTimeoutResponse)
 }
 
+func defaultHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ":\n\n")
+	return err
+}
+
 const (
 	pending = iota
 	timeout
@@ -89,7 +203,12 @@ type timeoutWriter struct {
 	done   chan<- int
 	cancel context.CancelCauseFunc
 
-	rollingTimer *time.Timer
+	rollingTimer Timer
+
+	// mu serializes access to the ResponseWriter and timers in streaming mode. It is nil otherwise.
+	mu             *sync.Mutex
+	heartbeatTimer Timer
+	stopped        bool
 
 	state   *atomic.Uint32
 	headers http.Header
@@ -98,7 +217,7 @@ type timeoutWriter struct {
 	Controller *http.ResponseController
 }
 
-func (w timeoutWriter) Timeout() {
+func (w *timeoutWriter) Timeout() {
 	if !w.state.CompareAndSwap(pending, timeout) {
 		return
 	}
@@ -107,23 +226,24 @@ func (w timeoutWriter) Timeout() {
 	w.done <- timeout
 }
 
-func (w timeoutWriter) Header() http.Header {
+func (w *timeoutWriter) Header() http.Header {
 	if w.state.Load() == writing {
 		return w.ResponseWriter.Header()
 	}
 	return w.headers
 }
 
-func (w timeoutWriter) tryWriting() bool {
+func (w *timeoutWriter) tryWriting() bool {
 	if w.state.CompareAndSwap(pending, writing) {
-		for key := range w.headers {
-			w.ResponseWriter.Header().Set(key, w.headers.Get(key))
+		for key, values := range w.headers {
+			w.ResponseWriter.Header()[key] = values
 		}
 	}
 	return w.state.Load() == writing
 }
 
-func (w timeoutWriter) WriteHeader(status int) {
+func (w *timeoutWriter) WriteHeader(status int) {
+	defer w.lock()()
 	if !w.tryWriting() {
 		return
 	}
@@ -131,11 +251,9 @@ func (w timeoutWriter) WriteHeader(status int) {
 }
 
 func (w *timeoutWriter) Write(data []byte) (n int, err error) {
+	defer w.lock()()
 	if !w.tryWriting() {
-		if w.state.Load() == hung {
-			return 0, ErrTimeoutDuringWrite
-		}
-		return 0, ErrTimeoutBeforeWrite
+		return 0, w.err()
 	}
 
 	n, err = w.ResponseWriter.Write(data)
@@ -143,22 +261,119 @@ func (w *timeoutWriter) Write(data []byte) (n int, err error) {
 		return
 	}
 
+	w.touch()
+
+	return
+}
+
+// Flush satisfies the http.Flusher interface. Use FlushError to observe flushing errors.
+func (w *timeoutWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError satisfies the implicit flushing interface of http.ResponseController.
+// In streaming mode, flushing resets the rolling timeout.
+func (w *timeoutWriter) FlushError() error {
+	defer w.lock()()
+	if !w.tryWriting() {
+		return w.err()
+	}
+
+	if err := w.Controller.Flush(); err != nil {
+		return err
+	}
+
+	if w.Stream != nil {
+		w.touch()
+	}
+
+	return nil
+}
+
+func (w *timeoutWriter) err() error {
+	if w.state.Load() == hung {
+		return ErrTimeoutDuringWrite
+	}
+	return ErrTimeoutBeforeWrite
+}
+
+func (w *timeoutWriter) lock() (unlock func()) {
+	if w.mu == nil {
+		return func() {}
+	}
+	w.mu.Lock()
+	return w.mu.Unlock
+}
+
+// touch resets the rolling and heartbeat timers. In streaming mode it must be called while holding the lock.
+func (w *timeoutWriter) touch() {
 	if w.Rolling > 0 {
 		if w.rollingTimer == nil {
-			w.rollingTimer = time.AfterFunc(w.Rolling, func() {
+			w.rollingTimer = w.Clock.AfterFunc(w.Rolling, func() {
 				if w.state.CompareAndSwap(writing, hung) {
 					w.done <- hung
 				}
 			})
+		} else {
+			w.rollingTimer.Reset(w.Rolling)
+		}
+	}
+
+	if w.Stream != nil && w.Stream.HeartbeatInterval > 0 {
+		if w.heartbeatTimer == nil {
+			w.heartbeatTimer = w.Clock.AfterFunc(w.Stream.HeartbeatInterval, w.heartbeat)
+		} else {
+			w.heartbeatTimer.Reset(w.Stream.HeartbeatInterval)
+		}
+	}
+}
+
+func (w *timeoutWriter) heartbeat() {
+	defer w.lock()()
+	if w.stopped || w.state.Load() != writing {
+		return
+	}
+	if err := w.Stream.Heartbeat(w.ResponseWriter); err != nil {
+		return
+	}
+	if err := w.Controller.Flush(); err != nil {
+		return
+	}
+	w.touch()
+}
+
+// closeStream writes the close frame of a stream that reached its rolling timeout. The write deadline is bounded by
+// the CloseTimeout such that any write blocked on a hung connection is released.
+func (w *timeoutWriter) closeStream() {
+	w.Controller.SetWriteDeadline(time.Now().Add(w.Stream.CloseTimeout))
+
+	defer w.lock()()
+
+	if w.Stream.Close != nil {
+		if err := w.Stream.Close(w.ResponseWriter); err != nil {
 			return
 		}
-		w.rollingTimer.Reset(w.Rolling)
+	}
+	if err := w.Controller.Flush(); err != nil {
+		return
 	}
 
-	return
+	w.Controller.SetWriteDeadline(time.Time{})
+}
+
+// stop prevents any further heartbeats from being written. It is only used in streaming mode.
+func (w *timeoutWriter) stop() {
+	defer w.lock()()
+	w.stopped = true
+	if w.heartbeatTimer != nil {
+		w.heartbeatTimer.Stop()
+	}
+	if w.rollingTimer != nil {
+		w.rollingTimer.Stop()
+	}
 }
 
 // Unwrap satisfies the implicit http.rwUnwrapper interface.
-func (w timeoutWriter) Unwrap() http.ResponseWriter {
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
 	return w.ResponseWriter
 }
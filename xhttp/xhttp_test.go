@@ -1,7 +1,9 @@
 package xhttp_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -10,13 +12,14 @@ import (
 	"time"
 
 	"github.com/davidmdm/x/xhttp"
+	"github.com/davidmdm/x/xhttp/xhttptest"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTimeoutHandler(t *testing.T) {
 	cases := []struct {
 		Name    string
-		Handler func(http.ResponseWriter, *http.Request) error
+		Handler func(*xhttptest.Clock, http.ResponseWriter, *http.Request) error
 
 		Opts xhttp.TimeoutOptions
 
@@ -30,7 +33,7 @@ func TestTimeoutHandler(t *testing.T) {
 	}{
 		{
 			Name: "happy",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
 				w.Header().Set("Test-Dirty-Write", "true")
 				_, err := io.WriteString(w, "success!")
 				return err
@@ -44,8 +47,8 @@ func TestTimeoutHandler(t *testing.T) {
 		},
 		{
 			Name: "basic initial timeout",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
-				time.Sleep(10 * time.Millisecond)
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
+				clock.Advance(10 * time.Millisecond)
 				w.Header().Set("Test-Dirty-Write", "true")
 				_, err := io.WriteString(w, "success!")
 				return err
@@ -65,8 +68,8 @@ func TestTimeoutHandler(t *testing.T) {
 		},
 		{
 			Name: "basic initial timeout with custom handler",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
-				time.Sleep(10 * time.Millisecond)
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
+				clock.Advance(10 * time.Millisecond)
 				w.Header().Set("Test-Dirty-Write", "true")
 				_, err := io.WriteString(w, "success!")
 				return err
@@ -92,7 +95,7 @@ func TestTimeoutHandler(t *testing.T) {
 		},
 		{
 			Name: "happying rolling response",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
 				stream := []struct {
 					data  string
 					delay time.Duration
@@ -107,7 +110,7 @@ func TestTimeoutHandler(t *testing.T) {
 				w.Header().Set("Content-Type", "application/json")
 
 				for _, value := range stream {
-					time.Sleep(value.delay)
+					clock.Advance(value.delay)
 					if _, err := io.WriteString(w, value.data); err != nil {
 						return err
 					}
@@ -124,7 +127,7 @@ func TestTimeoutHandler(t *testing.T) {
 		},
 		{
 			Name: "quick failed rolling response",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
 				stream := []struct {
 					data  string
 					delay time.Duration
@@ -139,7 +142,7 @@ func TestTimeoutHandler(t *testing.T) {
 				w.Header().Set("Content-Type", "application/json")
 
 				for _, value := range stream {
-					time.Sleep(value.delay)
+					clock.Advance(value.delay)
 					if _, err := io.WriteString(w, value.data); err != nil {
 						return err
 					}
@@ -160,7 +163,7 @@ func TestTimeoutHandler(t *testing.T) {
 		},
 		{
 			Name: "long failed rolling response",
-			Handler: func(w http.ResponseWriter, r *http.Request) error {
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
 				stream := []struct {
 					data  string
 					delay time.Duration
@@ -172,7 +175,7 @@ func TestTimeoutHandler(t *testing.T) {
 				w.Header().Set("Content-Type", "application/json")
 
 				for _, value := range stream {
-					time.Sleep(value.delay)
+					clock.Advance(value.delay)
 					if _, err := io.WriteString(w, value.data); err != nil {
 						return err
 					}
@@ -195,9 +198,12 @@ func TestTimeoutHandler(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.Name, func(t *testing.T) {
+			clock := xhttptest.NewClock(time.Now())
+			tc.Opts.Clock = clock
+
 			serveErr := make(chan error, 1)
 			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				serveErr <- tc.Handler(w, r)
+				serveErr <- tc.Handler(clock, w, r)
 				close(serveErr)
 			})
 
@@ -240,3 +246,138 @@ func TestTimeoutHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeoutHandlerStreaming(t *testing.T) {
+	closeFrame := func(w io.Writer) error {
+		_, err := io.WriteString(w, "event: close\ndata:\n\n")
+		return err
+	}
+
+	cases := []struct {
+		Name    string
+		Handler func(*xhttptest.Clock, http.ResponseWriter, *http.Request) error
+		Opts    xhttp.TimeoutOptions
+		// Drive runs alongside the request, to advance the clock while the handler is blocked.
+		Drive func(*xhttptest.Clock)
+
+		ExpectedServeError func(*testing.T, error)
+		ExpectedBody       func(*testing.T, string)
+	}{
+		{
+			Name: "heartbeats keep idle stream alive",
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Content-Type", "text/event-stream")
+				if _, err := io.WriteString(w, "data: start\n\n"); err != nil {
+					return err
+				}
+				clock.Advance(100 * time.Millisecond)
+				_, err := io.WriteString(w, "data: end\n\n")
+				return err
+			},
+			Opts: xhttp.TimeoutOptions{
+				Rolling: 30 * time.Millisecond,
+				Stream: &xhttp.StreamOptions{
+					HeartbeatInterval: 10 * time.Millisecond,
+				},
+			},
+			ExpectedBody: func(t *testing.T, body string) {
+				require.True(t, strings.HasPrefix(body, "data: start\n\n"), "unexpected body: %q", body)
+				require.True(t, strings.HasSuffix(body, "data: end\n\n"), "unexpected body: %q", body)
+				require.Contains(t, body, ":\n\n")
+			},
+		},
+		{
+			Name: "flushes reset rolling timeout",
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
+				if _, err := io.WriteString(w, "start,"); err != nil {
+					return err
+				}
+				for range 6 {
+					clock.Advance(10 * time.Millisecond)
+					if err := http.NewResponseController(w).Flush(); err != nil {
+						return err
+					}
+				}
+				_, err := io.WriteString(w, "end")
+				return err
+			},
+			Opts: xhttp.TimeoutOptions{
+				Rolling: 30 * time.Millisecond,
+				Stream:  &xhttp.StreamOptions{},
+			},
+			ExpectedBody: func(t *testing.T, body string) {
+				require.Equal(t, "start,end", body)
+			},
+		},
+		{
+			Name: "rolling timeout writes close frame",
+			Handler: func(clock *xhttptest.Clock, w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("Content-Type", "text/event-stream")
+				if _, err := io.WriteString(w, "data: start\n\n"); err != nil {
+					return err
+				}
+				<-r.Context().Done()
+				if _, err := io.WriteString(w, "data: too late\n\n"); err == nil {
+					return errors.New("expected write after timeout to fail")
+				}
+				return context.Cause(r.Context())
+			},
+			Opts: xhttp.TimeoutOptions{
+				Rolling: 20 * time.Millisecond,
+				Stream: &xhttp.StreamOptions{
+					Close: closeFrame,
+				},
+			},
+			Drive: func(clock *xhttptest.Clock) {
+				// Wait for the write of the handler to arm the rolling timer next to the initial one.
+				clock.BlockUntil(2)
+				clock.Advance(20 * time.Millisecond)
+			},
+			ExpectedServeError: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, context.Canceled)
+				require.ErrorIs(t, err, xhttp.ErrTimeoutDuringWrite)
+			},
+			ExpectedBody: func(t *testing.T, body string) {
+				require.Equal(t, "data: start\n\nevent: close\ndata:\n\n", body)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			clock := xhttptest.NewClock(time.Now())
+			tc.Opts.Clock = clock
+
+			serveErr := make(chan error, 1)
+
+			server := httptest.NewServer(xhttp.TimeoutHandler(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					serveErr <- tc.Handler(clock, w, r)
+				}),
+				tc.Opts,
+			))
+			defer server.Close()
+
+			if tc.Drive != nil {
+				go tc.Drive(clock)
+			}
+
+			resp, err := http.Get(server.URL)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, 200, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			tc.ExpectedBody(t, string(body))
+
+			if tc.ExpectedServeError != nil {
+				tc.ExpectedServeError(t, <-serveErr)
+				return
+			}
+			require.NoError(t, <-serveErr)
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package xhttptest provides utilities for testing timeout sensitive handlers deterministically.
+package xhttptest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+)
+
+// Clock is a fake clock satisfying xhttp.Clock. Time only moves forward when Advance is called, at which point
+// timers that become due are fired synchronously and in order. The zero value is ready to use.
+type Clock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*timer
+}
+
+var _ xhttp.Clock = (*Clock)(nil)
+
+// NewClock returns a fake clock whose current time is now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc satisfies the xhttp.Clock interface. The function f is called by Advance once the clock reaches d
+// from now.
+func (c *Clock) AfterFunc(d time.Duration, f func()) xhttp.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &timer{clock: c, fn: f}
+	c.schedule(t, d)
+
+	return t
+}
+
+// Advance moves the clock forward by d, firing every timer that becomes due along the way. Timers are fired in
+// the order of their deadlines, without holding any lock, such that they may safely reset or stop timers.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+
+	for {
+		idx := slices.IndexFunc(c.timers, func(t *timer) bool { return !t.when.After(target) })
+		if idx < 0 {
+			break
+		}
+
+		next := c.timers[idx]
+		for _, t := range c.timers {
+			if t.when.Before(next.when) {
+				next = t
+			}
+		}
+
+		c.remove(next)
+		c.now = next.when
+
+		c.mu.Unlock()
+		next.fn()
+		c.mu.Lock()
+	}
+
+	c.now = target
+	c.mu.Unlock()
+}
+
+// Timers returns the number of timers currently waiting to fire.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire. It allows tests to synchronize with
+// goroutines that arm timers before advancing the clock.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.condition().Wait()
+	}
+}
+
+func (c *Clock) condition() *sync.Cond {
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mu)
+	}
+	return c.cond
+}
+
+func (c *Clock) schedule(t *timer, d time.Duration) {
+	t.when = c.now.Add(d)
+	if !slices.Contains(c.timers, t) {
+		c.timers = append(c.timers, t)
+	}
+	c.condition().Broadcast()
+}
+
+func (c *Clock) remove(t *timer) bool {
+	idx := slices.Index(c.timers, t)
+	if idx < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, idx, idx+1)
+	c.condition().Broadcast()
+	return true
+}
+
+type timer struct {
+	clock *Clock
+	fn    func()
+	when  time.Time
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := slices.Contains(t.clock.timers, t)
+	t.clock.schedule(t, d)
+	return active
+}
+
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
@@ -0,0 +1,124 @@
+package xhttptest_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+	"github.com/davidmdm/x/xhttp/xhttptest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	clock := xhttptest.NewClock(time.Unix(0, 0))
+
+	var fired []string
+
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	clock.AfterFunc(1*time.Second, func() { fired = append(fired, "a") })
+	stopped := clock.AfterFunc(1500*time.Millisecond, func() { fired = append(fired, "stopped") })
+
+	var reset xhttp.Timer
+	reset = clock.AfterFunc(500*time.Millisecond, func() {
+		fired = append(fired, "reset")
+		reset.Reset(2 * time.Second)
+	})
+
+	require.Equal(t, 4, clock.Timers())
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+
+	clock.Advance(time.Second)
+	require.Equal(t, []string{"reset", "a"}, fired)
+	require.Equal(t, time.Unix(1, 0), clock.Now())
+
+	clock.Advance(2 * time.Second)
+	require.Equal(t, []string{"reset", "a", "b", "reset"}, fired)
+	require.Equal(t, 1, clock.Timers())
+}
+
+func TestTimeoutHandlerWithClock(t *testing.T) {
+	t.Run("completed", func(t *testing.T) {
+		clock := xhttptest.NewClock(time.Now())
+		results := xhttptest.NewResults()
+
+		handler := xhttp.TimeoutHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "hello")
+			}),
+			xhttp.TimeoutOptions{
+				Initial:    time.Second,
+				Clock:      clock,
+				OnComplete: results.Record,
+			},
+		)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		results.AssertCompleted(t)
+		require.Equal(t, "hello", w.Body.String())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		clock := xhttptest.NewClock(time.Now())
+		results := xhttptest.NewResults()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		handler := xhttp.TimeoutHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}),
+			xhttp.TimeoutOptions{
+				Initial:    time.Hour,
+				Clock:      clock,
+				OnComplete: results.Record,
+			},
+		)
+
+		w := httptest.NewRecorder()
+		go handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+
+		results.AssertTimeout(t)
+		require.Equal(t, 503, w.Code)
+	})
+
+	t.Run("hung", func(t *testing.T) {
+		clock := xhttptest.NewClock(time.Now())
+		results := xhttptest.NewResults()
+
+		release := make(chan struct{})
+		defer close(release)
+
+		handler := xhttp.TimeoutHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "partial")
+				<-release
+			}),
+			xhttp.TimeoutOptions{
+				Initial:    time.Hour,
+				Rolling:    time.Minute,
+				Clock:      clock,
+				OnComplete: results.Record,
+			},
+		)
+
+		w := httptest.NewRecorder()
+		go handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		// Wait for both the initial and the rolling timers to be armed.
+		clock.BlockUntil(2)
+		clock.Advance(time.Minute)
+
+		results.AssertHung(t)
+		require.Equal(t, "partial", w.Body.String())
+	})
+}
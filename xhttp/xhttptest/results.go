@@ -0,0 +1,82 @@
+package xhttptest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/davidmdm/x/xhttp"
+)
+
+// Results records how the requests served by a TimeoutHandler ended. Its Record method is meant to be used as
+// the OnComplete option of the TimeoutHandler:
+//
+//	results := xhttptest.NewResults()
+//	handler := xhttp.TimeoutHandler(handler, xhttp.TimeoutOptions{
+//		Initial:    time.Second,
+//		Clock:      clock,
+//		OnComplete: results.Record,
+//	})
+type Results struct {
+	ch chan error
+
+	// Wait is the real time allowed for a request to complete before an assertion fails. Defaults to 5 seconds.
+	Wait time.Duration
+}
+
+// NewResults returns a Results that can hold up to 64 unconsumed results.
+func NewResults() *Results {
+	return &Results{ch: make(chan error, 64)}
+}
+
+// Record satisfies the OnComplete signature of xhttp.TimeoutOptions.
+func (results *Results) Record(_ *http.Request, err error) {
+	results.ch <- err
+}
+
+// Next waits for the next request to complete and returns its result. It fails the test if no request completes
+// in time.
+func (results *Results) Next(t testing.TB) error {
+	t.Helper()
+
+	wait := results.Wait
+	if wait <= 0 {
+		wait = 5 * time.Second
+	}
+
+	select {
+	case err := <-results.ch:
+		return err
+	case <-time.After(wait):
+		t.Fatalf("no request completed within %s", wait)
+		return nil
+	}
+}
+
+// AssertCompleted asserts that the next request to complete was served by the handler without reaching a timeout.
+func (results *Results) AssertCompleted(t testing.TB) {
+	t.Helper()
+	if err := results.Next(t); err != nil {
+		t.Fatalf("expected request to complete but it ended with: %v", err)
+	}
+}
+
+// AssertTimeout asserts that the next request to complete reached its initial timeout before the first write.
+func (results *Results) AssertTimeout(t testing.TB) {
+	t.Helper()
+	results.assert(t, xhttp.ErrTimeoutBeforeWrite)
+}
+
+// AssertHung asserts that the next request to complete reached its rolling timeout during writing.
+func (results *Results) AssertHung(t testing.TB) {
+	t.Helper()
+	results.assert(t, xhttp.ErrTimeoutDuringWrite)
+}
+
+func (results *Results) assert(t testing.TB, target error) {
+	t.Helper()
+	if err := results.Next(t); !errors.Is(err, target) {
+		t.Fatalf("expected request to end with %q but got: %v", target, err)
+	}
+}
@@ -129,3 +129,52 @@ func ReadAll(ctx context.Context, src io.Reader) ([]byte, error) {
 	_, err := Copy(ctx, &dst, src, WaitForLastOp(true))
 	return dst.Bytes(), err
 }
+
+// NewReader returns a reader whose reads are cancelable via the context. If the context is canceled while a read
+// is in progress, Read returns immediately with the context's cause. The underlying read keeps running in the
+// background until it returns, and its result is discarded, so the reader must not be used after cancelation.
+func NewReader(ctx context.Context, src io.Reader) io.Reader {
+	return &reader{ctx: ctx, src: src}
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+type reader struct {
+	ctx     context.Context
+	src     io.Reader
+	buf     []byte
+	pending chan readResult
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, context.Cause(r.ctx)
+	}
+
+	if r.pending == nil {
+		// The background read cannot use p directly since p may be reused by the caller if the read is canceled.
+		if cap(r.buf) < len(p) {
+			r.buf = make([]byte, len(p))
+		}
+		buf := r.buf[:len(p)]
+
+		pending := make(chan readResult, 1)
+		go func() {
+			n, err := r.src.Read(buf)
+			pending <- readResult{n, err}
+		}()
+
+		r.pending = pending
+	}
+
+	select {
+	case result := <-r.pending:
+		r.pending = nil
+		return copy(p, r.buf[:result.n]), result.err
+	case <-r.ctx.Done():
+		return 0, context.Cause(r.ctx)
+	}
+}
@@ -297,6 +297,48 @@ func TestReadAll(t *testing.T) {
 	}
 }
 
+func TestNewReader(t *testing.T) {
+	t.Run("reads through", func(t *testing.T) {
+		expected := []byte(`Hello world`)
+
+		actual, err := io.ReadAll(NewReader(context.Background(), bytes.NewReader(expected)))
+		if err != nil {
+			t.Fatalf("expected err to be nil but got %#q", err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Fatalf("expect content to be %q but got %q", expected, actual)
+		}
+	})
+
+	t.Run("cancel blocked read", func(t *testing.T) {
+		cause := errors.New("stalled")
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+
+		unblockRead := make(chan struct{})
+		defer close(unblockRead)
+
+		time.AfterFunc(20*time.Millisecond, func() { cancel(cause) })
+
+		r := NewReader(ctx, ReaderFunc(func(b []byte) (int, error) {
+			<-unblockRead
+			return len(b), nil
+		}))
+
+		n, err := r.Read(make([]byte, 10))
+		if err != cause {
+			t.Fatalf("expected err to be %#q but got %#q", cause, err)
+		}
+		if n != 0 {
+			t.Fatalf("expected n to be 0 but got %d", n)
+		}
+
+		if _, err := r.Read(make([]byte, 10)); err != cause {
+			t.Fatalf("expected subsequent read err to be %#q but got %#q", cause, err)
+		}
+	})
+}
+
 type ReaderFunc func([]byte) (int, error)
 
 func (fn ReaderFunc) Read(data []byte) (int, error) { return fn(data) }